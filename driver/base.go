@@ -1,13 +1,12 @@
 package driver
 
 import (
-	"context"
+	"fmt"
 	"github.com/aarioai/airis/aa"
 	"github.com/aarioai/airis/aa/ae"
 	"github.com/aarioai/airis/pkg/afmt"
 	"github.com/aarioai/airis/pkg/types"
 	"strings"
-	"sync"
 	"time"
 )
 
@@ -58,66 +57,58 @@ func tryGetSectionCfg(app *aa.App, base, section string, key string, defaultValu
 	if section == "" {
 		section = base
 	}
-	k := section + "." + key
-	v, err := app.Config.MustGetString(k)
-	defaultV := afmt.First(defaultValue)
-	if err != nil {
-		if section != base {
-			if !strings.HasPrefix(section, base+"_") {
-				// 尝试section加 mysql_/redis_/mongodb_... 开头
-				return tryGetSectionCfg(app, base, base+"_"+section, key)
+	v, resolved, err := lookupSectionCfg(app, base, section, key)
+	if err == nil && v != "" {
+		// env:$NAME / file:$path / ${VAR} 等引用，url 中的值已随 url 解析过
+		if !resolved {
+			if v, err = resolveConfigValue(v); err != nil {
+				err = fmt.Errorf("%s.%s: %w", section, key, err)
 			}
-			// 读取默认值，即 mysql.$key/redis.$key/mongodb.$key...
-			v, err = app.Config.MustGetString(base + "." + key)
+		}
+		if err == nil {
+			addConfigSecret(key, v)
 		}
 	}
 	if v == "" {
-		v = defaultV
+		v = afmt.First(defaultValue)
 	}
 	return v, err
 }
 
-func CloseAllPools(ctx context.Context) {
-	if ctx == nil {
-		ctx = context.Background()
-	}
-	var wg sync.WaitGroup
-
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		CloseInfluxdbPool()
-	}()
-
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		CloseMongodbPool(ctx)
-	}()
-
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		CloseMysqlPool()
-	}()
-
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		CloseRabbitmqPool()
-	}()
-
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		CloseRedisPool()
-	}()
+// rawSectionCfg reads the value of section.key, it's unresolved unless it was taken from a url. The lookup order is:
+// section.key, section.url, base.key, base.url
+func rawSectionCfg(app *aa.App, base, section string, key string) (string, error) {
+	v, _, err := lookupSectionCfg(app, base, section, key)
+	return v, err
+}
 
-	wg.Wait()
+// lookupSectionCfg is rawSectionCfg, resolved is true if v was taken from the (resolved) url
+func lookupSectionCfg(app *aa.App, base, section string, key string) (string, bool, error) {
+	v, err := app.Config.MustGetString(section + "." + key)
+	if err == nil {
+		return v, false, nil
+	}
+	if uv, ok, uerr := sectionURLCfg(app, base, section, key); uerr != nil || ok {
+		return uv, true, uerr
+	}
+	if section != base {
+		if !strings.HasPrefix(section, base+"_") {
+			// 尝试section加 mysql_/redis_/mongodb_... 开头
+			return lookupSectionCfg(app, base, base+"_"+section, key)
+		}
+		// 读取默认值，即 mysql.$key/redis.$key/mongodb.$key...
+		if v, err = app.Config.MustGetString(base + "." + key); err == nil {
+			return v, false, nil
+		}
+		if uv, ok, uerr := sectionURLCfg(app, base, base, key); uerr != nil || ok {
+			return uv, true, uerr
+		}
+	}
+	return v, false, err
 }
 
 func newConfigError(section string, err error) *ae.Error {
-	return ae.NewF(ae.VariantAlsoNegotiates, "config section [%s] error: %s", section, err.Error())
+	return ae.NewF(ae.VariantAlsoNegotiates, "config section [%s] error: %s", section, Redact(err.Error()))
 }
 
 func parseStrings(s, separator string) []string {
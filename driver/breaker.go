@@ -0,0 +1,318 @@
+package driver
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aarioai/airis/aa"
+	"github.com/aarioai/airis/aa/ae"
+	"github.com/aarioai/airis/pkg/types"
+	"github.com/go-sql-driver/mysql"
+)
+
+const (
+	DefaultBreakerMinRequests      = 20
+	DefaultBreakerWindow           = 10 * time.Second
+	DefaultBreakerCoolDown         = 5 * time.Second
+	DefaultBreakerHalfOpenRequests = 1
+)
+
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"
+	BreakerOpen     BreakerState = "open"      // fails fast until the cool-down ends
+	BreakerHalfOpen BreakerState = "half_open" // lets a few trial requests through
+)
+
+var (
+	// ErrCircuitOpen is returned by the client libraries (e.g. redis commands) when the breaker is open
+	ErrCircuitOpen = errors.New("circuit breaker open")
+	// ErrorCircuitOpen is returned by New*Error and sqlx when the breaker is open, it's not retryable
+	ErrorCircuitOpen = ae.New(ae.ServiceUnavailable, "circuit breaker open").Lock()
+)
+
+// BreakerConfig is read from breaker_failure_rate, breaker_min_requests, breaker_window, breaker_cool_down and
+// breaker_half_open_requests of the section. The breaker is disabled if breaker_failure_rate is 0.
+type BreakerConfig struct {
+	FailureRate      int           // percent of failed requests in the window to open the breaker, 1-100
+	MinRequests      int           // minimum requests in the window before the failure rate is evaluated
+	Window           time.Duration // the counters are reset every window
+	CoolDown         time.Duration // open -> half-open
+	HalfOpenRequests int           // trial requests in half-open state, all of them must succeed to close the breaker
+}
+
+// BreakerEvent is emitted when the state of a breaker changed
+type BreakerEvent struct {
+	Kind    PoolKind
+	Section string
+	From    BreakerState
+	To      BreakerState
+}
+
+// Breaker is a circuit breaker of a section, every request calls Allow, and Done with the result if allowed.
+// A nil *Breaker allows everything.
+type Breaker struct {
+	kind    PoolKind
+	section string
+
+	mtx         sync.Mutex
+	config      BreakerConfig
+	state       BreakerState
+	windowStart time.Time
+	requests    int
+	failures    int
+	openedAt    time.Time
+	trials      int // allowed trial requests in half-open state
+	succeeded   int // succeeded trial requests
+	opened      uint64
+}
+
+func NewBreaker(kind PoolKind, section string, config BreakerConfig) *Breaker {
+	return &Breaker{kind: kind, section: section, config: config, state: BreakerClosed, windowStart: time.Now()}
+}
+
+func (b *Breaker) State() BreakerState {
+	if b == nil {
+		return BreakerClosed
+	}
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.state
+}
+
+// Opened returns how many times the breaker opened
+func (b *Breaker) Opened() uint64 {
+	if b == nil {
+		return 0
+	}
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.opened
+}
+
+func (b *Breaker) setConfig(c BreakerConfig) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	b.config = c
+}
+
+// transit must be called with the lock held, it returns the event to emit after unlocking
+func (b *Breaker) transit(to BreakerState, now time.Time) *BreakerEvent {
+	from := b.state
+	if from == to {
+		return nil
+	}
+	b.state = to
+	b.requests, b.failures, b.trials, b.succeeded = 0, 0, 0, 0
+	b.windowStart = now
+	if to == BreakerOpen {
+		b.openedAt = now
+		b.opened++
+	}
+	return &BreakerEvent{Kind: b.kind, Section: b.section, From: from, To: to}
+}
+
+// Allow returns ErrCircuitOpen if the request should fail fast
+func (b *Breaker) Allow() error {
+	if b == nil {
+		return nil
+	}
+	now := time.Now()
+	b.mtx.Lock()
+	var ev *BreakerEvent
+	var err error
+	switch b.state {
+	case BreakerOpen:
+		if now.Sub(b.openedAt) < b.config.CoolDown {
+			err = ErrCircuitOpen
+			break
+		}
+		ev = b.transit(BreakerHalfOpen, now)
+		b.trials++
+	case BreakerHalfOpen:
+		if b.trials >= b.config.HalfOpenRequests {
+			err = ErrCircuitOpen
+			break
+		}
+		b.trials++
+	}
+	b.mtx.Unlock()
+	emitBreakerEvent(ev)
+	return err
+}
+
+// Ready reports whether Allow would let a request through now, without taking a trial slot. It's used to pick a
+// healthy one among several sections, e.g. mysql replicas.
+func (b *Breaker) Ready() bool {
+	if b == nil {
+		return true
+	}
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	switch b.state {
+	case BreakerOpen:
+		return time.Since(b.openedAt) >= b.config.CoolDown
+	case BreakerHalfOpen:
+		return b.trials < b.config.HalfOpenRequests
+	}
+	return true
+}
+
+// Done records the result of an allowed request. Only unavailability (timeouts, connection and pool errors) counts as
+// a failure, e.g. not found, duplicate key or deadlock don't.
+func (b *Breaker) Done(err error) {
+	if b == nil {
+		return
+	}
+	failed := isBreakerFailure(err)
+	now := time.Now()
+	b.mtx.Lock()
+	var ev *BreakerEvent
+	switch b.state {
+	case BreakerClosed:
+		if now.Sub(b.windowStart) >= b.config.Window {
+			b.windowStart, b.requests, b.failures = now, 0, 0
+		}
+		b.requests++
+		if failed {
+			b.failures++
+		}
+		if b.requests >= b.config.MinRequests && b.failures*100 >= b.config.FailureRate*b.requests {
+			ev = b.transit(BreakerOpen, now)
+		}
+	case BreakerHalfOpen:
+		if failed {
+			ev = b.transit(BreakerOpen, now)
+		} else if b.succeeded++; b.succeeded >= b.config.HalfOpenRequests {
+			ev = b.transit(BreakerClosed, now)
+		}
+	}
+	b.mtx.Unlock()
+	emitBreakerEvent(ev)
+}
+
+func isBreakerFailure(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, ErrCircuitOpen) {
+		return false
+	}
+	if ErrorClass(err) == "timeout" {
+		return true
+	}
+	var ne net.Error
+	if errors.As(err, &ne) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) {
+		return true
+	}
+	code, _ := classifyRedisError(err)
+	return code == ae.ServiceUnavailable
+}
+
+var (
+	breakers        sync.Map // poolKey -> *Breaker
+	breakerMtx      sync.RWMutex
+	breakerHandlers []func(BreakerEvent)
+)
+
+// OnBreakerChange registers a handler called when the state of any breaker changed, see HealthMonitor.WatchBreakers
+func OnBreakerChange(handler func(BreakerEvent)) {
+	breakerMtx.Lock()
+	defer breakerMtx.Unlock()
+	breakerHandlers = append(breakerHandlers, handler)
+}
+
+func emitBreakerEvent(ev *BreakerEvent) {
+	if ev == nil {
+		return
+	}
+	breakerMtx.RLock()
+	handlers := slices.Clone(breakerHandlers)
+	breakerMtx.RUnlock()
+	for _, handler := range handlers {
+		handler(*ev)
+	}
+}
+
+func ParseBreakerConfig(app *aa.App, base, section string) BreakerConfig {
+	rate, _ := tryGetSectionCfg(app, base, section, "breaker_failure_rate")
+	minRequests, _ := tryGetSectionCfg(app, base, section, "breaker_min_requests")
+	window, _ := tryGetSectionCfg(app, base, section, "breaker_window")
+	coolDown, _ := tryGetSectionCfg(app, base, section, "breaker_cool_down")
+	halfOpen, _ := tryGetSectionCfg(app, base, section, "breaker_half_open_requests")
+	c := BreakerConfig{
+		Window:   types.ParseDuration(window, DefaultBreakerWindow),
+		CoolDown: types.ParseDuration(coolDown, DefaultBreakerCoolDown),
+	}
+	c.FailureRate, _ = types.ParseInt(rate)
+	c.FailureRate = min(c.FailureRate, 100)
+	if c.MinRequests, _ = types.ParseInt(minRequests); c.MinRequests <= 0 {
+		c.MinRequests = DefaultBreakerMinRequests
+	}
+	if c.HalfOpenRequests, _ = types.ParseInt(halfOpen); c.HalfOpenRequests <= 0 {
+		c.HalfOpenRequests = DefaultBreakerHalfOpenRequests
+	}
+	return c
+}
+
+// loadBreaker is called after the client of the section was (re)built or reloaded, the state of an existing breaker is kept
+func loadBreaker(app *aa.App, kind PoolKind, section string) {
+	key := poolKey{kind: kind, section: section}
+	c := ParseBreakerConfig(app, string(kind), section)
+	if c.FailureRate <= 0 {
+		breakers.Delete(key)
+		return
+	}
+	if b, ok := breakers.Load(key); ok {
+		b.(*Breaker).setConfig(c)
+		return
+	}
+	breakers.Store(key, NewBreaker(kind, section, c))
+}
+
+// SectionBreaker returns the breaker of the mysql or redis section, nil if not configured
+func SectionBreaker(kind PoolKind, section string) *Breaker {
+	if b, ok := breakers.Load(poolKey{kind: kind, section: section}); ok {
+		return b.(*Breaker)
+	}
+	return nil
+}
+
+type bypassBreakerKey struct{}
+
+// BypassBreaker lets the operations with ctx skip the breaker, e.g. health probes. They are not recorded either.
+func BypassBreaker(ctx context.Context) context.Context {
+	return context.WithValue(ctx, bypassBreakerKey{}, true)
+}
+
+// ContextBreaker returns the breaker of the section, nil if not configured or ctx bypasses it
+func ContextBreaker(ctx context.Context, kind PoolKind, section string) *Breaker {
+	if ctx != nil {
+		if v, _ := ctx.Value(bypassBreakerKey{}).(bool); v {
+			return nil
+		}
+	}
+	return SectionBreaker(kind, section)
+}
+
+// breakerList returns all breakers sorted by kind and section
+func breakerList() []*Breaker {
+	var list []*Breaker
+	breakers.Range(func(_, v any) bool {
+		list = append(list, v.(*Breaker))
+		return true
+	})
+	slices.SortFunc(list, func(a, b *Breaker) int {
+		if c := strings.Compare(string(a.kind), string(b.kind)); c != 0 {
+			return c
+		}
+		return strings.Compare(a.section, b.section)
+	})
+	return list
+}
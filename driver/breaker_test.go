@@ -0,0 +1,107 @@
+package driver_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aarioai/airis-driver/driver"
+	"github.com/aarioai/airis/aa"
+	"github.com/aarioai/airis/aa/aconfig"
+	"github.com/aarioai/airis/aa/acontext"
+	"github.com/aarioai/airis/aa/ae"
+)
+
+func TestBreaker(t *testing.T) {
+	b := driver.NewBreaker(driver.PoolKindMysql, "test", driver.BreakerConfig{
+		FailureRate:      50,
+		MinRequests:      4,
+		Window:           time.Minute,
+		CoolDown:         20 * time.Millisecond,
+		HalfOpenRequests: 1,
+	})
+	for _, err := range []error{nil, driver.ErrCircuitOpen, context.DeadlineExceeded, nil} {
+		if b.Allow() != nil {
+			t.Fatal("closed breaker should allow")
+		}
+		b.Done(err)
+	}
+	if b.State() != driver.BreakerClosed {
+		t.Fatalf("1 failure of 4 should not open the breaker, got %s", b.State())
+	}
+	for i := 0; i < 4; i++ {
+		b.Allow()
+		b.Done(context.DeadlineExceeded)
+	}
+	if b.State() != driver.BreakerOpen || b.Allow() != driver.ErrCircuitOpen {
+		t.Fatalf("breaker should be open, got %s", b.State())
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if b.Allow() != nil || b.State() != driver.BreakerHalfOpen {
+		t.Fatalf("breaker should let a trial through after cool-down, got %s", b.State())
+	}
+	if b.Allow() != driver.ErrCircuitOpen {
+		t.Error("half-open breaker should allow only 1 trial")
+	}
+	b.Done(nil)
+	if b.State() != driver.BreakerClosed || b.Opened() != 1 {
+		t.Errorf("breaker should be closed after a successful trial, got %s", b.State())
+	}
+
+	var nilBreaker *driver.Breaker
+	if nilBreaker.Allow() != nil {
+		t.Error("nil breaker should allow")
+	}
+}
+
+func TestRedisBreaker(t *testing.T) {
+	c, err := aconfig.New("./test_config.ini", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := acontext.WithCancel(acontext.Background())
+	app := aa.New(ctx, cancel, c)
+	defer driver.ClosePools(context.Background(), driver.PoolKindRedis)
+
+	var mtx sync.Mutex
+	var events []driver.BreakerEvent
+	driver.OnBreakerChange(func(ev driver.BreakerEvent) {
+		if ev.Section == "redis_breaker" {
+			mtx.Lock()
+			events = append(events, ev)
+			mtx.Unlock()
+		}
+	})
+	m := driver.NewHealthMonitor(time.Hour, time.Second).WatchBreakers()
+
+	rdb, e := driver.NewRedisPool(app, "redis_breaker") // unreachable, breaker_failure_rate = 50, breaker_min_requests = 2
+	if e != nil {
+		t.Fatal(e.Msg)
+	}
+	m.Watch(driver.PoolKindRedis, "redis_breaker")
+	for i := 0; i < 2; i++ {
+		rdb.Get(context.Background(), "user:1")
+	}
+	err = rdb.Get(context.Background(), "user:1").Err()
+	if e = driver.NewRedisError(err); e != driver.ErrorCircuitOpen || driver.IsRetryable(e) {
+		t.Fatalf("open breaker should fail fast, got %v", err)
+	}
+	mtx.Lock()
+	if len(events) != 1 || events[0].To != driver.BreakerOpen {
+		t.Errorf("breaker events: %+v", events)
+	}
+	mtx.Unlock()
+	if s, _ := m.State(string(driver.PoolKindRedis), "redis_breaker"); s.Status != driver.HealthDown || s.Breaker != driver.BreakerOpen {
+		t.Errorf("health state: %+v", s)
+	}
+
+	// health probes bypass the breaker
+	if err = driver.PingPool(context.Background(), driver.PoolKindRedis, "redis_breaker"); err == nil || err.Error() == driver.ErrCircuitOpen.Error() {
+		t.Errorf("ping should reach redis, got %v", err)
+	}
+	if e.Code != ae.ServiceUnavailable {
+		t.Errorf("circuit open code %d", e.Code)
+	}
+}
@@ -0,0 +1,86 @@
+package driver_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aarioai/airis-driver/driver"
+	"github.com/aarioai/airis/aa"
+	"github.com/aarioai/airis/aa/aconfig"
+	"github.com/aarioai/airis/aa/acontext"
+)
+
+func TestValidateConfig(t *testing.T) {
+	t.Setenv("AIRIS_DRIVER_TEST_REDIS_PASSWORD", "Luexu.com")
+	t.Setenv("AIRIS_DRIVER_TEST_REDIS_HOST", "luexu.com")
+	c, err := aconfig.New("./test_config.ini", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := acontext.WithCancel(acontext.Background())
+	app := aa.New(ctx, cancel, c)
+	keys, err := driver.ConfigFileKeys("./test_config.ini")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = driver.ValidateConfig(app, keys...); err != nil {
+		t.Error(err)
+	}
+
+	file := filepath.Join(t.TempDir(), "config.ini")
+	ini := `[redis]
+addr = luexu.com
+pool_size = 10
+min_idle_conns = 20
+timeout = 5s,abc
+
+[redis_bad]
+pool_szie = 10
+
+[mysql]
+user = Aario
+password = env:AIRIS_DRIVER_TEST_NOT_EXISTS
+
+[mongodb_bad]
+hosts = a:27017,b:27017
+direct = true
+writer_concern = majority
+`
+	if err = os.WriteFile(file, []byte(ini), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if app.Config, err = aconfig.New(file, nil); err != nil {
+		t.Fatal(err)
+	}
+	var issues driver.ConfigIssues
+	if keys, err = driver.ConfigFileKeys(file); err != nil {
+		t.Fatal(err)
+	}
+	if err = driver.ValidateConfig(app, keys...); !errors.As(err, &issues) {
+		t.Fatalf("validate bad config: %v", err)
+	}
+	wants := map[[2]string]bool{
+		{"redis", "timeout"}:              false,
+		{"redis", ""}:                     false, // min_idle_conns > pool_size
+		{"redis_bad", "pool_szie"}:        false,
+		{"redis_bad", ""}:                 false, // inherited conflict
+		{"mysql", "host"}:                 false,
+		{"mysql", "password"}:             false,
+		{"mongodb_bad", ""}:               false,
+		{"mongodb_bad", "writer_concern"}: false,
+	}
+	for _, issue := range issues {
+		key := [2]string{issue.Section, issue.Key}
+		if _, ok := wants[key]; !ok {
+			t.Errorf("unexpected issue %s", issue)
+		}
+		wants[key] = true
+	}
+	for key, found := range wants {
+		if !found {
+			t.Errorf("missing issue of [%s] %s", key[0], key[1])
+		}
+	}
+}
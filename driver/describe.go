@@ -0,0 +1,213 @@
+package driver
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/aarioai/airis/aa"
+	"github.com/aarioai/airis/aa/alog"
+)
+
+// SectionDescription is the effective parsed options of a driver config section, with secrets redacted
+type SectionDescription struct {
+	Base    string         `json:"base"` // mysql, postgres, sqlite, redis, mongodb, rabbitmq, influxdb, httpc
+	Section string         `json:"section"`
+	Pooled  bool           `json:"pooled"` // options are taken from the live pooled client
+	Options map[string]any `json:"options,omitempty"`
+	Error   string         `json:"error,omitempty"`
+}
+
+func (d SectionDescription) String() string {
+	b, _ := json.Marshal(d)
+	return string(b)
+}
+
+// Describe parses every driver section of app.Config. Pooled sections show the options the client was built from.
+// keys are the written config keys as section.key, e.g. from ConfigFileKeys. If no keys are given and app.Config can't
+// list them, only the base sections and the sections of live pooled clients are described.
+func Describe(app *aa.App, keys ...string) []SectionDescription {
+	defer beginSecretPass()()
+	var names []string
+	if len(keys) > 0 {
+		_, names = groupConfigKeys(keys)
+	} else {
+		names = configSectionNames(app)
+	}
+	descs := make([]SectionDescription, 0, len(names))
+	for _, section := range names {
+		base, _, ok := configSchemaOf(section)
+		if !ok {
+			continue
+		}
+		d := SectionDescription{Base: base, Section: section}
+		var opts any
+		var err error
+		if entry, ok := loadPooledSection(PoolKind(base), section); ok {
+			d.Pooled = true
+			opts = entry.config
+		} else {
+			opts, err = parseSectionOptions(app, base, section)
+		}
+		if err != nil {
+			d.Error = Redact(err.Error())
+		} else if m, ok := describeValue(reflect.ValueOf(opts), "", 0).(map[string]any); ok {
+			d.Options = m
+		}
+		descs = append(descs, d)
+	}
+	return descs
+}
+
+// LogDescribe logs the Describe result, one line per section
+func LogDescribe(app *aa.App, keys ...string) {
+	for _, d := range Describe(app, keys...) {
+		alog.Printf("driver config %s", d)
+	}
+}
+
+// loadPooledSection finds the pooled client of [mysql_test], which may be pooled as "test" or "mysql_test"
+func loadPooledSection(kind PoolKind, section string) (*poolEntry, bool) {
+	if entry, ok := pools.load(poolKey{kind: kind, section: section}); ok {
+		return entry, true
+	}
+	if short, ok := strings.CutPrefix(section, string(kind)+"_"); ok {
+		return pools.load(poolKey{kind: kind, section: short})
+	}
+	return nil, false
+}
+
+func parseSectionOptions(app *aa.App, base, section string) (any, error) {
+	switch base {
+	case "redis":
+		return ParseRedisConfig(app, section)
+	case "mysql":
+		return ParseMysqlConfig(app, section)
+	case "postgres":
+		return ParsePostgresConfig(app, section)
+	case "sqlite":
+		return ParseSqliteConfig(app, section)
+	case "mongodb":
+		return ParseMongodbConfig(app, section)
+	case "rabbitmq":
+		return ParseRabbitmqConfig(app, section, nil, nil)
+	case "influxdb":
+		return ParseInfluxdbConfig(app, section)
+	case "httpc":
+		return ParseHttpClientConfig(app, section)
+	}
+	return nil, fmt.Errorf("unknown driver %s", base)
+}
+
+// isSecretField 按字段名判断是否为密码、token、私钥等
+func isSecretField(name string) bool {
+	name = strings.ToLower(strings.ReplaceAll(name, "_", ""))
+	if name == "auth" {
+		return true
+	}
+	for _, s := range []string{"password", "passwd", "token", "secret", "privatekey", "credential"} {
+		if strings.Contains(name, s) {
+			return true
+		}
+	}
+	return false
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// describeValue converts options into json-friendly values, secrets are redacted
+func describeValue(v reflect.Value, name string, depth int) any {
+	if !v.IsValid() || depth > 6 {
+		return nil
+	}
+	if isSecretField(name) && !v.IsZero() && v.Kind() != reflect.Struct && v.Kind() != reflect.Pointer {
+		return RedactedMark
+	}
+	if v.Type() == durationType {
+		return v.Interface().(time.Duration).String()
+	}
+	switch v.Kind() {
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		return nil
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		if c, ok := v.Interface().(*tls.Config); ok {
+			// 不输出证书、私钥
+			return map[string]any{
+				"server_name":          c.ServerName,
+				"insecure_skip_verify": c.InsecureSkipVerify,
+				"min_version":          c.MinVersion,
+				"max_version":          c.MaxVersion,
+				"certificates":         len(c.Certificates),
+			}
+		}
+		// 如 *time.Location
+		if s, ok := v.Interface().(fmt.Stringer); ok && v.Kind() == reflect.Pointer && !hasExportedField(v.Type().Elem()) {
+			return s.String()
+		}
+		return describeValue(v.Elem(), name, depth+1)
+	case reflect.Struct:
+		m := make(map[string]any)
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			key := f.Name
+			if tag, _, _ := strings.Cut(f.Tag.Get("json"), ","); tag == "-" {
+				continue
+			} else if tag != "" {
+				key = tag
+			}
+			if fv := describeValue(v.Field(i), f.Name, depth+1); fv != nil {
+				m[key] = fv
+			}
+		}
+		return m
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return fmt.Sprintf("<%d bytes>", v.Len())
+		}
+		arr := make([]any, v.Len())
+		for i := range arr {
+			arr[i] = describeValue(v.Index(i), name, depth+1)
+		}
+		return arr
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		m := make(map[string]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			k := fmt.Sprint(iter.Key().Interface())
+			m[k] = describeValue(iter.Value(), k, depth+1)
+		}
+		return m
+	case reflect.String:
+		// 密码字段已按字段名隐藏，这里只处理 url 里的密码
+		return redactPatterns(v.String())
+	}
+	return v.Interface()
+}
+
+func hasExportedField(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct {
+		return true
+	}
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).IsExported() {
+			return true
+		}
+	}
+	return false
+}
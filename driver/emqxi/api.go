@@ -2,7 +2,9 @@ package emqxi
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"github.com/aarioai/airis/aa/ae"
 	"net/http"
 )
@@ -51,3 +53,23 @@ func (s *EmqxAPI) Request(target any, method, path string) (*ae.Error, error) {
 	err = json.Unmarshal(buf.Bytes(), &emqxError)
 	return NewError(emqxError), nil
 }
+
+// Ping checks the node status, GET {Host}/status responds 200 when emqx is running
+func (s *EmqxAPI) Ping(ctx context.Context) error {
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, s.Host+"/status", nil)
+	if err != nil {
+		return err
+	}
+	if s.username != "" {
+		r.SetBasicAuth(s.username, s.password)
+	}
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("emqx status: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,389 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aarioai/airis-driver/driver/emqxi"
+)
+
+const (
+	DefaultHealthInterval = 10 * time.Second
+	DefaultHealthTimeout  = 3 * time.Second
+)
+
+// 非连接池的检查目标
+const (
+	HealthKindHttp = "http"
+	HealthKindEmqx = "emqx"
+)
+
+type HealthStatus string
+
+const (
+	HealthUnknown HealthStatus = "unknown" // not checked yet
+	HealthUp      HealthStatus = "up"
+	HealthDown    HealthStatus = "down"
+	// HealthDegraded is only reported by ReadinessStatus: critical targets are up, some non-critical ones are not
+	HealthDegraded HealthStatus = "degraded"
+)
+
+// HealthState is the latest probe result of a section
+type HealthState struct {
+	Kind      string        `json:"kind"` // PoolKind, HealthKindHttp or HealthKindEmqx
+	Section   string        `json:"section"`
+	Status    HealthStatus  `json:"status"`
+	Latency   time.Duration `json:"latency"`
+	LastError string        `json:"last_error,omitempty"` // redacted
+	LastCheck time.Time     `json:"last_check"`
+	Since     time.Time     `json:"since"`             // when the status changed
+	Failures  int           `json:"failures"`          // consecutive failures
+	Critical  bool          `json:"critical"`          // a non-critical section being down degrades readiness instead of failing it
+	Breaker   BreakerState  `json:"breaker,omitempty"` // set by WatchBreakers
+}
+
+type HealthProbe func(ctx context.Context) error
+
+// HealthChangeHandler is called when the status of a section changed, e.g. up -> down
+type HealthChangeHandler func(prev, curr HealthState)
+
+type healthTarget struct {
+	probe HealthProbe
+	state HealthState
+}
+
+// HealthMonitor periodically probes pooled clients and other targets, without opening new connections
+type HealthMonitor struct {
+	interval  time.Duration
+	timeout   time.Duration
+	threshold int
+
+	mtx      sync.RWMutex
+	targets  map[string]*healthTarget
+	handlers []HealthChangeHandler
+	notifyMu sync.Mutex // handlers are called one by one
+
+	runMtx sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewHealthMonitor creates a monitor that probes every interval, each probe is limited by timeout
+func NewHealthMonitor(interval, timeout time.Duration) *HealthMonitor {
+	if interval <= 0 {
+		interval = DefaultHealthInterval
+	}
+	if timeout <= 0 {
+		timeout = DefaultHealthTimeout
+	}
+	return &HealthMonitor{
+		interval:  interval,
+		timeout:   timeout,
+		threshold: 1,
+		targets:   make(map[string]*healthTarget),
+	}
+}
+
+// WithFailureThreshold marks a section down only after n consecutive failures
+func (m *HealthMonitor) WithFailureThreshold(n int) *HealthMonitor {
+	if n > 0 {
+		m.threshold = n
+	}
+	return m
+}
+
+func healthKey(kind, section string) string {
+	return kind + "/" + section
+}
+
+// AddProbe adds (or replaces) a custom target
+func (m *HealthMonitor) AddProbe(kind, section string, probe HealthProbe) *HealthMonitor {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	key := healthKey(kind, section)
+	if t, ok := m.targets[key]; ok {
+		t.probe = probe
+		return m
+	}
+	m.targets[key] = &healthTarget{
+		probe: probe,
+		state: HealthState{Kind: kind, Section: section, Status: HealthUnknown, Critical: true},
+	}
+	return m
+}
+
+// Watch adds pooled sections. A section that is not pooled (or closed) is reported down, it is never built by the monitor.
+func (m *HealthMonitor) Watch(kind PoolKind, sections ...string) *HealthMonitor {
+	for _, section := range sections {
+		m.AddProbe(string(kind), section, func(ctx context.Context) error {
+			return PingPool(ctx, kind, section)
+		})
+	}
+	return m
+}
+
+// WatchPools adds every pooled section of the kinds, or of all PoolKinds if kinds is empty
+func (m *HealthMonitor) WatchPools(kinds ...PoolKind) *HealthMonitor {
+	if len(kinds) == 0 {
+		kinds = PoolKinds
+	}
+	for _, kind := range kinds {
+		m.Watch(kind, PoolSections(kind)...)
+	}
+	return m
+}
+
+// SetCritical marks whether a target is critical, targets are critical by default
+func (m *HealthMonitor) SetCritical(kind, section string, critical bool) *HealthMonitor {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	if t, ok := m.targets[healthKey(kind, section)]; ok {
+		t.state.Critical = critical
+	}
+	return m
+}
+
+// WatchHttp adds a http target, it's up if GET link responds with a status code < 500
+func (m *HealthMonitor) WatchHttp(name string, client *http.Client, link string) *HealthMonitor {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return m.AddProbe(HealthKindHttp, name, func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= http.StatusInternalServerError {
+			return fmt.Errorf("GET %s: %s", link, resp.Status)
+		}
+		return nil
+	})
+}
+
+// WatchEmqx adds an EMQX broker, see emqxi.EmqxAPI.Ping
+func (m *HealthMonitor) WatchEmqx(name string, api *emqxi.EmqxAPI) *HealthMonitor {
+	return m.AddProbe(HealthKindEmqx, name, api.Ping)
+}
+
+// Remove stops watching a target
+func (m *HealthMonitor) Remove(kind, section string) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	delete(m.targets, healthKey(kind, section))
+}
+
+// OnChange registers a handler called when the status of a section changed
+func (m *HealthMonitor) OnChange(handler HealthChangeHandler) *HealthMonitor {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.handlers = append(m.handlers, handler)
+	return m
+}
+
+// Start probes all targets immediately and then every interval, until ctx is done or Stop is called
+func (m *HealthMonitor) Start(ctx context.Context) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	m.runMtx.Lock()
+	defer m.runMtx.Unlock()
+	if m.cancel != nil {
+		return
+	}
+	ctx, m.cancel = context.WithCancel(ctx)
+	m.done = make(chan struct{})
+	go func(done chan struct{}) {
+		defer close(done)
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+		for {
+			m.Check(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}(m.done)
+}
+
+// Stop stops the background probing and waits for the running check
+func (m *HealthMonitor) Stop() {
+	m.runMtx.Lock()
+	defer m.runMtx.Unlock()
+	if m.cancel == nil {
+		return
+	}
+	m.cancel()
+	<-m.done
+	m.cancel = nil
+}
+
+// Check probes all targets concurrently now, and returns the states
+func (m *HealthMonitor) Check(ctx context.Context) []HealthState {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	m.mtx.RLock()
+	keys := make([]string, 0, len(m.targets))
+	probes := make([]HealthProbe, 0, len(m.targets))
+	for key, t := range m.targets {
+		keys = append(keys, key)
+		probes = append(probes, t.probe)
+	}
+	m.mtx.RUnlock()
+
+	var wg sync.WaitGroup
+	for i, probe := range probes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pctx, cancel := context.WithTimeout(ctx, m.timeout)
+			defer cancel()
+			start := time.Now()
+			err := probe(pctx)
+			m.update(keys[i], time.Since(start), err)
+		}()
+	}
+	wg.Wait()
+	return m.States()
+}
+
+func (m *HealthMonitor) update(key string, latency time.Duration, err error) {
+	now := time.Now()
+	m.mtx.Lock()
+	t, ok := m.targets[key]
+	if !ok {
+		m.mtx.Unlock()
+		return
+	}
+	prev := t.state
+	s := &t.state
+	s.Latency = latency
+	s.LastCheck = now
+	if err == nil {
+		s.Failures = 0
+		s.LastError = ""
+		s.Status = HealthUp
+	} else {
+		s.Failures++
+		s.LastError = Redact(err.Error())
+		if s.Failures >= m.threshold || s.Status == HealthUnknown {
+			s.Status = HealthDown
+		}
+	}
+	changed := prev.Status != s.Status
+	if changed {
+		s.Since = now
+	}
+	curr := *s
+	handlers := slices.Clone(m.handlers)
+	m.mtx.Unlock()
+
+	if changed {
+		m.notify(handlers, prev, curr)
+	}
+}
+
+func (m *HealthMonitor) notify(handlers []HealthChangeHandler, prev, curr HealthState) {
+	m.notifyMu.Lock()
+	defer m.notifyMu.Unlock()
+	for _, handler := range handlers {
+		handler(prev, curr)
+	}
+}
+
+// WatchBreakers tracks the circuit breakers of the watched sections. An opening breaker marks the section down at once,
+// the next probe (which bypasses the breaker) reports the real status again.
+func (m *HealthMonitor) WatchBreakers() *HealthMonitor {
+	OnBreakerChange(m.breakerChanged)
+	return m
+}
+
+func (m *HealthMonitor) breakerChanged(ev BreakerEvent) {
+	now := time.Now()
+	m.mtx.Lock()
+	t, ok := m.targets[healthKey(string(ev.Kind), ev.Section)]
+	if !ok {
+		m.mtx.Unlock()
+		return
+	}
+	prev := t.state
+	s := &t.state
+	s.Breaker = ev.To
+	if ev.To == BreakerOpen && s.Status != HealthDown {
+		s.Status = HealthDown
+		s.LastError = ErrCircuitOpen.Error()
+		s.Since = now
+	}
+	curr := *s
+	handlers := slices.Clone(m.handlers)
+	m.mtx.Unlock()
+
+	if prev.Status != curr.Status {
+		m.notify(handlers, prev, curr)
+	}
+}
+
+// WatchKeepalive takes the keepalive pings of the watched sections as probe results, so an outage is reported before
+// the next probe
+func (m *HealthMonitor) WatchKeepalive() *HealthMonitor {
+	OnKeepalive(func(ev KeepaliveEvent) {
+		m.update(healthKey(string(ev.Kind), ev.Section), ev.Latency, ev.Err)
+	})
+	return m
+}
+
+// States returns the latest states of all targets, sorted by kind and section
+func (m *HealthMonitor) States() []HealthState {
+	m.mtx.RLock()
+	states := make([]HealthState, 0, len(m.targets))
+	for _, t := range m.targets {
+		states = append(states, t.state)
+	}
+	m.mtx.RUnlock()
+	slices.SortFunc(states, func(a, b HealthState) int {
+		if c := strings.Compare(a.Kind, b.Kind); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Section, b.Section)
+	})
+	return states
+}
+
+// State returns the latest state of a target
+func (m *HealthMonitor) State(kind, section string) (HealthState, bool) {
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+	t, ok := m.targets[healthKey(kind, section)]
+	if !ok {
+		return HealthState{}, false
+	}
+	return t.state, true
+}
+
+func (m *HealthMonitor) running() bool {
+	m.runMtx.Lock()
+	defer m.runMtx.Unlock()
+	return m.cancel != nil
+}
+
+// Healthy reports whether all targets are up
+func (m *HealthMonitor) Healthy() bool {
+	for _, s := range m.States() {
+		if s.Status != HealthUp {
+			return false
+		}
+	}
+	return true
+}
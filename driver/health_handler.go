@@ -0,0 +1,93 @@
+package driver
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+type healthDependency struct {
+	Kind      string       `json:"kind"`
+	Section   string       `json:"section"`
+	Status    HealthStatus `json:"status"`
+	Critical  bool         `json:"critical"`
+	LatencyMs float64      `json:"latency_ms"`
+	Error     string       `json:"error,omitempty"`
+	CheckedAt *time.Time   `json:"checked_at,omitempty"`
+	Since     *time.Time   `json:"since,omitempty"`
+}
+
+type healthResponse struct {
+	Status       HealthStatus       `json:"status"`
+	Dependencies []healthDependency `json:"dependencies"`
+}
+
+// ReadinessStatus summarizes the states: down if any critical target is not up, degraded if any non-critical target
+// is not up, otherwise up
+func ReadinessStatus(states []HealthState) HealthStatus {
+	status := HealthUp
+	for _, s := range states {
+		if s.Status == HealthUp {
+			continue
+		}
+		if s.Critical {
+			return HealthDown
+		}
+		status = HealthDegraded
+	}
+	return status
+}
+
+// ReadinessHandler reports every target as JSON, responds 503 if a critical target is not up.
+// The targets are checked on each request if the monitor is not started.
+func (m *HealthMonitor) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var states []HealthState
+		if m.running() {
+			states = m.States()
+		} else {
+			states = m.Check(r.Context())
+		}
+		resp := healthResponse{
+			Status:       ReadinessStatus(states),
+			Dependencies: make([]healthDependency, 0, len(states)),
+		}
+		for _, s := range states {
+			d := healthDependency{
+				Kind:      s.Kind,
+				Section:   s.Section,
+				Status:    s.Status,
+				Critical:  s.Critical,
+				LatencyMs: float64(s.Latency.Microseconds()) / 1000,
+				Error:     s.LastError,
+			}
+			if !s.LastCheck.IsZero() {
+				d.CheckedAt = &s.LastCheck
+			}
+			if !s.Since.IsZero() {
+				d.Since = &s.Since
+			}
+			resp.Dependencies = append(resp.Dependencies, d)
+		}
+		code := http.StatusOK
+		if resp.Status == HealthDown {
+			code = http.StatusServiceUnavailable
+		}
+		writeHealthJSON(w, code, resp)
+	})
+}
+
+// LivenessHandler always responds 200 while the process is serving, dependencies never fail liveness, otherwise
+// kubernetes would restart every pod when a database is down
+func (m *HealthMonitor) LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeHealthJSON(w, http.StatusOK, healthResponse{Status: HealthUp, Dependencies: []healthDependency{}})
+	})
+}
+
+func writeHealthJSON(w http.ResponseWriter, code int, resp healthResponse) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(resp)
+}
@@ -163,8 +163,13 @@ func NewHttpClient(app *aa.App, section string) (*http.Client, *ae.Error) {
 		return nil, newConfigError(section, err)
 	}
 
+	// 每次重试单独追踪
+	var rt http.RoundTripper = httpTraceTransport{section: section, base: transport}
+	if policy := ParseRetryPolicy(app, "httpc", section); policy.Enabled() {
+		rt = httpRetryTransport{policy: policy, base: rt}
+	}
 	return &http.Client{
-		Transport:     transport,
+		Transport:     rt,
 		CheckRedirect: nil,
 		Jar:           nil,
 		Timeout:       c.Timeout,
@@ -172,6 +177,7 @@ func NewHttpClient(app *aa.App, section string) (*http.Client, *ae.Error) {
 }
 
 func ParseHttpClientConfig(app *aa.App, section string) (HttpClientConfig, error) {
+	defer beginSecretPass()()
 	timeout, _ := tryGetSectionCfg(app, "httpc", section, "timeout")
 	dialTimeout, _ := tryGetSectionCfg(app, "httpc", section, "dial_timeout")
 	dialDualStack, _ := tryGetSectionCfg(app, "httpc", section, "dial_dual_stack")
@@ -291,5 +297,5 @@ func ParseHttpClientConfig(app *aa.App, section string) (HttpClientConfig, error
 }
 
 func NewHttpError(err error) *ae.Error {
-	return ae.NewErr(err)
+	return ae.NewErr(RedactError(err))
 }
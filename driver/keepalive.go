@@ -0,0 +1,230 @@
+package driver
+
+import (
+	"context"
+	"net"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aarioai/airis/aa"
+	"github.com/aarioai/airis/aa/alog"
+	"github.com/aarioai/airis/pkg/types"
+)
+
+const (
+	DefaultKeepaliveTimeout  = 3 * time.Second
+	DefaultKeepaliveFailures = 3
+)
+
+// KeepaliveConfig is read from keepalive_interval, keepalive_timeout and keepalive_failures of the section.
+// The keepalive is disabled if keepalive_interval is 0.
+type KeepaliveConfig struct {
+	Interval time.Duration
+	Timeout  time.Duration // of each ping
+	Failures int           // consecutive failures that make an outage, the pool is recycled when it recovers
+	// it's also the consecutive pings that must resolve the same new addresses before the pool is recycled for a dns change
+}
+
+// KeepaliveEvent is emitted after every keepalive ping
+type KeepaliveEvent struct {
+	Kind     PoolKind
+	Section  string
+	Latency  time.Duration
+	Err      error  // redacted
+	Failures int    // consecutive failures
+	Recycled string // the reason if the pool was recycled, e.g. "recovered" or "dns changed"
+}
+
+// keepalive pings a pooled section in background. It recycles the pool (see RecyclePool) when the section recovers
+// from an outage, e.g. the server restarted, or the addresses of the host changed. It stops when the pool is closed.
+type keepalive struct {
+	key    poolKey
+	config atomic.Pointer[KeepaliveConfig]
+	stop   chan struct{}
+	once   sync.Once
+
+	failures atomic.Int32
+	addrs    []string
+	newAddrs []string // the changed addresses waiting to be confirmed by the next pings
+	newSeen  int
+}
+
+var (
+	keepalives        sync.Map // poolKey -> *keepalive
+	keepaliveMtx      sync.RWMutex
+	keepaliveHandlers []func(KeepaliveEvent)
+)
+
+// OnKeepalive registers a handler called after every keepalive ping, see HealthMonitor.WatchKeepalive
+func OnKeepalive(handler func(KeepaliveEvent)) {
+	keepaliveMtx.Lock()
+	defer keepaliveMtx.Unlock()
+	keepaliveHandlers = append(keepaliveHandlers, handler)
+}
+
+func emitKeepaliveEvent(ev KeepaliveEvent) {
+	keepaliveMtx.RLock()
+	handlers := slices.Clone(keepaliveHandlers)
+	keepaliveMtx.RUnlock()
+	for _, handler := range handlers {
+		handler(ev)
+	}
+}
+
+func ParseKeepaliveConfig(app *aa.App, base, section string) KeepaliveConfig {
+	interval, _ := tryGetSectionCfg(app, base, section, "keepalive_interval")
+	timeout, _ := tryGetSectionCfg(app, base, section, "keepalive_timeout")
+	failures, _ := tryGetSectionCfg(app, base, section, "keepalive_failures")
+	c := KeepaliveConfig{
+		Interval: types.ParseDuration(interval),
+		Timeout:  types.ParseDuration(timeout, DefaultKeepaliveTimeout),
+	}
+	if c.Failures, _ = types.ParseInt(failures); c.Failures <= 0 {
+		c.Failures = DefaultKeepaliveFailures
+	}
+	return c
+}
+
+// loadKeepalive is called after the client of the section was (re)built or reloaded, a running keepalive takes the new config at the next ping
+func loadKeepalive(app *aa.App, kind PoolKind, section string) {
+	key := poolKey{kind: kind, section: section}
+	c := ParseKeepaliveConfig(app, string(kind), section)
+	if c.Interval <= 0 {
+		stopKeepalive(key)
+		return
+	}
+	k := &keepalive{key: key, stop: make(chan struct{})}
+	k.config.Store(&c)
+	if v, loaded := keepalives.LoadOrStore(key, k); loaded {
+		v.(*keepalive).config.Store(&c)
+		return
+	}
+	go k.run()
+}
+
+// KeepaliveDown reports whether the keepalive of the pooled section is in an outage, i.e. keepalive_failures
+// consecutive pings failed. It's false if the section has no keepalive.
+func KeepaliveDown(kind PoolKind, section string) bool {
+	v, ok := keepalives.Load(poolKey{kind: kind, section: section})
+	if !ok {
+		return false
+	}
+	k := v.(*keepalive)
+	return int(k.failures.Load()) >= k.config.Load().Failures
+}
+
+func stopKeepalive(key poolKey) {
+	if v, ok := keepalives.LoadAndDelete(key); ok {
+		v.(*keepalive).close()
+	}
+}
+
+func (k *keepalive) close() {
+	k.once.Do(func() { close(k.stop) })
+}
+
+func (k *keepalive) run() {
+	t := time.NewTimer(k.config.Load().Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-k.stop:
+			return
+		case <-t.C:
+		}
+		if !k.ping() {
+			keepalives.CompareAndDelete(k.key, k)
+			k.close()
+			return
+		}
+		t.Reset(k.config.Load().Interval)
+	}
+}
+
+// ping returns false if the section is not pooled anymore
+func (k *keepalive) ping() bool {
+	entry, ok := pools.load(k.key)
+	if !ok {
+		return false
+	}
+	c := k.config.Load()
+	ctx, cancel := context.WithTimeout(BypassBreaker(context.Background()), c.Timeout)
+	defer cancel()
+	start := time.Now()
+	err := pingPoolClient(ctx, entry.client)
+	ev := KeepaliveEvent{Kind: k.key.kind, Section: k.key.section, Latency: time.Since(start), Err: RedactError(err)}
+
+	if err != nil {
+		if n := int(k.failures.Add(1)); n == c.Failures {
+			alog.Printf("%s [%s] keepalive: %d consecutive failures: %s", k.key.kind, k.key.section, n, ev.Err)
+		}
+	} else {
+		if int(k.failures.Load()) >= c.Failures {
+			ev.Recycled = "recovered"
+		}
+		k.failures.Store(0)
+	}
+	ev.Failures = int(k.failures.Load())
+	if k.dnsChanged(entry.config, c) && ev.Recycled == "" {
+		ev.Recycled = "dns changed"
+	}
+	if ev.Recycled != "" {
+		alog.Printf("%s [%s] keepalive: %s, recycle the pool", k.key.kind, k.key.section, ev.Recycled)
+		if _, e := RecyclePool(context.Background(), k.key.kind, k.key.section); e != nil {
+			alog.Printf("%s [%s] keepalive recycle: %s", k.key.kind, k.key.section, e.Msg)
+		}
+	}
+	emitKeepaliveEvent(ev)
+	return true
+}
+
+// dnsChanged reports whether the hosts resolved to the same new addresses in c.Failures consecutive pings, so that
+// a flapping or round-robin dns answer won't recycle the pool
+func (k *keepalive) dnsChanged(config any, c *KeepaliveConfig) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), c.Timeout)
+	defer cancel()
+	addrs := k.resolve(ctx, config)
+	if addrs == nil {
+		return false
+	}
+	if k.addrs == nil || slices.Equal(addrs, k.addrs) {
+		k.addrs = addrs
+		k.newAddrs, k.newSeen = nil, 0
+		return false
+	}
+	if slices.Equal(addrs, k.newAddrs) {
+		k.newSeen++
+	} else {
+		k.newAddrs, k.newSeen = addrs, 1
+	}
+	if k.newSeen < c.Failures {
+		return false
+	}
+	k.addrs = addrs
+	k.newAddrs, k.newSeen = nil, 0
+	return true
+}
+
+// resolve returns the sorted addresses of the hosts, nil if the hosts are IPs or the lookup failed
+func (k *keepalive) resolve(ctx context.Context, config any) []string {
+	f, ok := config.(MysqlOptions)
+	if !ok {
+		return nil
+	}
+	var addrs []string
+	for _, hostPort := range f.Hosts() {
+		host, _, _ := net.SplitHostPort(hostPort)
+		if host == "" || net.ParseIP(host) != nil {
+			continue
+		}
+		a, err := net.DefaultResolver.LookupHost(ctx, host)
+		if err != nil || len(a) == 0 {
+			return nil
+		}
+		addrs = append(addrs, a...)
+	}
+	slices.Sort(addrs)
+	return slices.Compact(addrs)
+}
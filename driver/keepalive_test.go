@@ -0,0 +1,60 @@
+package driver_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aarioai/airis-driver/driver"
+	"github.com/aarioai/airis/aa"
+	"github.com/aarioai/airis/aa/aconfig"
+	"github.com/aarioai/airis/aa/acontext"
+)
+
+func TestKeepalive(t *testing.T) {
+	c, err := aconfig.New("./test_config.ini", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := acontext.WithCancel(acontext.Background())
+	app := aa.New(ctx, cancel, c)
+
+	var pings, failures atomic.Int64
+	driver.OnKeepalive(func(ev driver.KeepaliveEvent) {
+		if ev.Kind == driver.PoolKindMysql && ev.Section == "mysql_keepalive" {
+			pings.Add(1)
+			if ev.Err != nil {
+				failures.Store(int64(ev.Failures))
+			}
+		}
+	})
+	m := driver.NewHealthMonitor(time.Hour, time.Second).Watch(driver.PoolKindMysql, "mysql_keepalive").WatchKeepalive()
+
+	if _, _, e := driver.NewMysqlPool(app, "mysql_keepalive"); e != nil {
+		t.Fatal(e.Msg)
+	}
+	deadline := time.Now().Add(3 * time.Second)
+	for failures.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if failures.Load() < 2 {
+		t.Fatalf("keepalive consecutive failures %d, want >= 2", failures.Load())
+	}
+	if !driver.KeepaliveDown(driver.PoolKindMysql, "mysql_keepalive") {
+		t.Error("keepalive should report the outage")
+	}
+	if s, ok := m.State(string(driver.PoolKindMysql), "mysql_keepalive"); !ok || s.Status != driver.HealthDown {
+		t.Errorf("keepalive failures should mark the section down: %+v", s)
+	}
+
+	if err = driver.ClosePool(context.Background(), driver.PoolKindMysql, "mysql_keepalive"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	n := pings.Load()
+	time.Sleep(100 * time.Millisecond)
+	if pings.Load() != n {
+		t.Error("keepalive should stop after the pool is closed")
+	}
+}
@@ -0,0 +1,285 @@
+package driver
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aarioai/airis/aa/alog"
+	http2 "github.com/influxdata/influxdb-client-go/v2/api/http"
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/redis/go-redis/v9"
+	"github.com/wagslane/go-rabbitmq"
+	"go.mongodb.org/mongo-driver/v2/event"
+)
+
+// MetricsNamespace 指标名前缀
+const MetricsNamespace = "airis_driver"
+
+// sectionMetrics are the counters that can't be read from the clients, they are collected by hooks set when the
+// pool is connected, and kept across reloads
+type sectionMetrics struct {
+	// mongodb event.PoolMonitor
+	mongodbConns          atomic.Int64
+	mongodbCheckedOut     atomic.Int64
+	mongodbCheckouts      atomic.Int64
+	mongodbCheckoutFailed atomic.Int64
+	mongodbCheckoutWait   atomic.Int64 // ns
+	mongodbCleared        atomic.Int64
+
+	// rabbitmq amqp Config.Dial
+	rabbitmqConnGen    atomic.Int64 // the latest dialed socket
+	rabbitmqDown       atomic.Bool
+	rabbitmqReconnects atomic.Int64
+
+	// influxdb WriteAPI failed callback
+	influxdbWriteFailed  atomic.Int64
+	influxdbWriteRetries atomic.Int64
+}
+
+var poolMetrics sync.Map // poolKey -> *sectionMetrics
+
+func sectionMetricsOf(kind PoolKind, section string) *sectionMetrics {
+	m, _ := poolMetrics.LoadOrStore(poolKey{kind: kind, section: section}, new(sectionMetrics))
+	return m.(*sectionMetrics)
+}
+
+func mongodbPoolMonitor(section string) *event.PoolMonitor {
+	m := sectionMetricsOf(PoolKindMongodb, section)
+	return &event.PoolMonitor{
+		Event: func(e *event.PoolEvent) {
+			switch e.Type {
+			case event.ConnectionCreated:
+				m.mongodbConns.Add(1)
+			case event.ConnectionClosed:
+				m.mongodbConns.Add(-1)
+			case event.ConnectionCheckedOut:
+				m.mongodbCheckedOut.Add(1)
+				m.mongodbCheckouts.Add(1)
+				m.mongodbCheckoutWait.Add(int64(e.Duration))
+			case event.ConnectionCheckedIn:
+				m.mongodbCheckedOut.Add(-1)
+			case event.ConnectionCheckOutFailed:
+				m.mongodbCheckoutFailed.Add(1)
+				m.mongodbCheckoutWait.Add(int64(e.Duration))
+			case event.ConnectionPoolCleared:
+				m.mongodbCleared.Add(1)
+			}
+		},
+	}
+}
+
+// rabbitmqDialTimeout is the default of amqp091
+const rabbitmqDialTimeout = 30 * time.Second
+
+// rabbitmqConn calls closed once when the socket fails or is closed
+type rabbitmqConn struct {
+	net.Conn
+	once   sync.Once
+	closed func()
+}
+
+func (c *rabbitmqConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if err != nil {
+		c.once.Do(c.closed)
+	}
+	return n, err
+}
+
+func (c *rabbitmqConn) Close() error {
+	c.once.Do(c.closed)
+	return c.Conn.Close()
+}
+
+// withRabbitmqMetrics appends an option that wraps amqp Config.Dial to track the connection state. go-rabbitmq dials
+// again to reconnect, so the connection is down after the socket of the latest dial is closed, and up after the next
+// dial. Sockets of a replaced connection (e.g. after reload) are ignored.
+func withRabbitmqMetrics(section string, opts []func(*rabbitmq.ConnectionOptions)) []func(*rabbitmq.ConnectionOptions) {
+	m := sectionMetricsOf(PoolKindRabbitmq, section)
+	return append(opts, func(o *rabbitmq.ConnectionOptions) {
+		dial := o.Config.Dial
+		if dial == nil {
+			dial = amqp091.DefaultDial(rabbitmqDialTimeout)
+		}
+		var dialed atomic.Bool
+		o.Config.Dial = func(network, addr string) (net.Conn, error) {
+			conn, err := dial(network, addr)
+			if err != nil {
+				return nil, err
+			}
+			gen := m.rabbitmqConnGen.Add(1)
+			m.rabbitmqDown.Store(false)
+			if dialed.Swap(true) {
+				m.rabbitmqReconnects.Add(1)
+			}
+			return &rabbitmqConn{Conn: conn, closed: func() {
+				if m.rabbitmqConnGen.Load() == gen {
+					m.rabbitmqDown.Store(true)
+				}
+			}}, nil
+		}
+	})
+}
+
+// influxdbWriteFailed counts the failed batches, and keeps the default behaviour to retry
+func influxdbWriteFailed(section string) func(batch string, err http2.Error, retryAttempts uint) bool {
+	m := sectionMetricsOf(PoolKindInfluxdb, section)
+	return func(batch string, err http2.Error, retryAttempts uint) bool {
+		m.influxdbWriteFailed.Add(1)
+		if retryAttempts > 0 {
+			m.influxdbWriteRetries.Add(1)
+		}
+		return true
+	}
+}
+
+type metricFamily struct {
+	name    string
+	typ     string
+	help    string
+	samples []string
+}
+
+// metricsWriter groups samples by family, in the order they are first added
+type metricsWriter struct {
+	families []*metricFamily
+	index    map[string]*metricFamily
+}
+
+func (w *metricsWriter) add(name, typ, help string, kind PoolKind, section string, v float64) {
+	name = MetricsNamespace + "_" + name
+	f, ok := w.index[name]
+	if !ok {
+		f = &metricFamily{name: name, typ: typ, help: help}
+		w.index[name] = f
+		w.families = append(w.families, f)
+	}
+	sample := fmt.Sprintf(`%s{kind="%s",section="%s"} %s`, name, kind, escapeLabel(section), strconv.FormatFloat(v, 'g', -1, 64))
+	f.samples = append(f.samples, sample)
+}
+
+func (w *metricsWriter) gauge(name, help string, kind PoolKind, section string, v float64) {
+	w.add(name, "gauge", help, kind, section, v)
+}
+
+func (w *metricsWriter) counter(name, help string, kind PoolKind, section string, v float64) {
+	w.add(name, "counter", help, kind, section, v)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func boolMetric(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// collectMetrics collects the stats of every pooled section
+func collectMetrics() *metricsWriter {
+	w := &metricsWriter{index: make(map[string]*metricFamily)}
+	for _, kind := range []PoolKind{PoolKindMysql, PoolKindPostgres, PoolKindSqlite} {
+		for _, section := range PoolSections(kind) {
+			d, ok := PoolClient[SqlClientData](kind, section)
+			if !ok {
+				continue
+			}
+			s := d.Client.Stats()
+			w.gauge("pool_max_open_connections", "Maximum number of open connections.", kind, section, float64(s.MaxOpenConnections))
+			w.gauge("pool_open_connections", "Number of established connections, both in use and idle.", kind, section, float64(s.OpenConnections))
+			w.gauge("pool_in_use_connections", "Number of connections currently in use.", kind, section, float64(s.InUse))
+			w.gauge("pool_idle_connections", "Number of idle connections.", kind, section, float64(s.Idle))
+			w.counter("pool_wait_total", "Total number of connections waited for.", kind, section, float64(s.WaitCount))
+			w.counter("pool_wait_seconds_total", "Total time blocked waiting for a new connection.", kind, section, s.WaitDuration.Seconds())
+			w.counter("pool_max_idle_closed_total", "Total number of connections closed due to max idle conns.", kind, section, float64(s.MaxIdleClosed))
+			w.counter("pool_max_idle_time_closed_total", "Total number of connections closed due to max idle time.", kind, section, float64(s.MaxIdleTimeClosed))
+			w.counter("pool_max_lifetime_closed_total", "Total number of connections closed due to max lifetime.", kind, section, float64(s.MaxLifetimeClosed))
+		}
+	}
+	for _, section := range PoolSections(PoolKindRedis) {
+		c, ok := PoolClient[*redis.Client](PoolKindRedis, section)
+		if !ok {
+			continue
+		}
+		s := c.PoolStats()
+		w.gauge("pool_open_connections", "Number of established connections, both in use and idle.", PoolKindRedis, section, float64(s.TotalConns))
+		w.gauge("pool_idle_connections", "Number of idle connections.", PoolKindRedis, section, float64(s.IdleConns))
+		w.counter("pool_wait_total", "Total number of connections waited for.", PoolKindRedis, section, float64(s.WaitCount))
+		w.counter("pool_wait_seconds_total", "Total time blocked waiting for a new connection.", PoolKindRedis, section, time.Duration(s.WaitDurationNs).Seconds())
+		w.counter("pool_hits_total", "Total number of times a free connection was found in the pool.", PoolKindRedis, section, float64(s.Hits))
+		w.counter("pool_misses_total", "Total number of times a free connection was not found in the pool.", PoolKindRedis, section, float64(s.Misses))
+		w.counter("pool_timeouts_total", "Total number of wait timeouts.", PoolKindRedis, section, float64(s.Timeouts))
+		w.counter("pool_stale_closed_total", "Total number of stale connections removed from the pool.", PoolKindRedis, section, float64(s.StaleConns))
+	}
+	for _, section := range PoolSections(PoolKindMongodb) {
+		m := sectionMetricsOf(PoolKindMongodb, section)
+		w.gauge("pool_open_connections", "Number of established connections, both in use and idle.", PoolKindMongodb, section, float64(m.mongodbConns.Load()))
+		w.gauge("pool_in_use_connections", "Number of connections currently in use.", PoolKindMongodb, section, float64(m.mongodbCheckedOut.Load()))
+		w.counter("pool_checkouts_total", "Total number of connections checked out.", PoolKindMongodb, section, float64(m.mongodbCheckouts.Load()))
+		w.counter("pool_checkout_failed_total", "Total number of failed connection checkouts.", PoolKindMongodb, section, float64(m.mongodbCheckoutFailed.Load()))
+		w.counter("pool_wait_seconds_total", "Total time blocked waiting for a new connection.", PoolKindMongodb, section, time.Duration(m.mongodbCheckoutWait.Load()).Seconds())
+		w.counter("pool_cleared_total", "Total number of times the pool was cleared.", PoolKindMongodb, section, float64(m.mongodbCleared.Load()))
+	}
+	for _, section := range PoolSections(PoolKindRabbitmq) {
+		m := sectionMetricsOf(PoolKindRabbitmq, section)
+		w.gauge("connection_up", "Whether the connection is established.", PoolKindRabbitmq, section, boolMetric(!m.rabbitmqDown.Load()))
+		w.counter("connection_reconnects_total", "Total number of connections dialed again after the connection was lost.", PoolKindRabbitmq, section, float64(m.rabbitmqReconnects.Load()))
+	}
+	for _, section := range PoolSections(PoolKindInfluxdb) {
+		m := sectionMetricsOf(PoolKindInfluxdb, section)
+		if c, ok := PoolConfig[InfluxdbConfig](PoolKindInfluxdb, section); ok {
+			o := c.Options(nil)
+			// influxdb client 不提供缓冲区的实际深度，只输出配置值
+			w.gauge("write_config_batch_size", "Configured maximum number of points sent in a single write.", PoolKindInfluxdb, section, float64(o.BatchSize()))
+			w.gauge("write_config_retry_buffer_limit", "Configured maximum number of points kept for retry.", PoolKindInfluxdb, section, float64(o.RetryBufferLimit()))
+		}
+		w.counter("write_failed_total", "Total number of failed batch writes.", PoolKindInfluxdb, section, float64(m.influxdbWriteFailed.Load()))
+		w.counter("write_retries_failed_total", "Total number of failed batch write retries.", PoolKindInfluxdb, section, float64(m.influxdbWriteRetries.Load()))
+	}
+	for _, b := range breakerList() {
+		w.gauge("breaker_open", "Whether the circuit breaker is open or half-open.", b.kind, b.section, boolMetric(b.State() != BreakerClosed))
+		w.counter("breaker_opened_total", "Total number of times the circuit breaker opened.", b.kind, b.section, float64(b.Opened()))
+	}
+	return w
+}
+
+// WriteTo writes the metrics in Prometheus text exposition format
+func (w *metricsWriter) WriteTo(out io.Writer) (int64, error) {
+	bw := bufio.NewWriter(out)
+	var n int
+	for _, f := range w.families {
+		k, _ := fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s %s\n", f.name, f.help, f.name, f.typ)
+		n += k
+		for _, sample := range f.samples {
+			k, _ = bw.WriteString(sample + "\n")
+			n += k
+		}
+	}
+	return int64(n), bw.Flush()
+}
+
+// WriteMetrics writes the stats of every pooled section in Prometheus text exposition format
+func WriteMetrics(out io.Writer) error {
+	_, err := collectMetrics().WriteTo(out)
+	return err
+}
+
+// MetricsHandler serves the pool stats for Prometheus scraping
+func MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		alog.OnError(WriteMetrics(w))
+	})
+}
@@ -0,0 +1,46 @@
+package driver_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aarioai/airis-driver/driver"
+	"github.com/aarioai/airis/aa"
+	"github.com/aarioai/airis/aa/aconfig"
+	"github.com/aarioai/airis/aa/acontext"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	c, err := aconfig.New("./test_config.ini", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := acontext.WithCancel(acontext.Background())
+	app := aa.New(ctx, cancel, c)
+	defer driver.ClosePools(context.Background(), driver.PoolKindRedis)
+	if _, e := driver.NewRedisPool(app, "redis_test"); e != nil {
+		t.Fatal(e.Msg)
+	}
+
+	w := httptest.NewRecorder()
+	driver.MetricsHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := w.Body.String()
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Errorf("metrics content type: %s", w.Header().Get("Content-Type"))
+	}
+	for _, want := range []string{
+		"# TYPE airis_driver_pool_open_connections gauge\n",
+		`airis_driver_pool_open_connections{kind="redis",section="redis_test"} 0` + "\n",
+		"# TYPE airis_driver_pool_hits_total counter\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics should contain %q:\n%s", want, body)
+		}
+	}
+	if strings.Count(body, "# TYPE airis_driver_pool_open_connections ") != 1 {
+		t.Errorf("metric family should be written once:\n%s", body)
+	}
+}
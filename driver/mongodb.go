@@ -2,17 +2,19 @@ package driver
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"github.com/aarioai/airis/aa"
 	"github.com/aarioai/airis/aa/ae"
 	"github.com/aarioai/airis/aa/alog"
 	"github.com/aarioai/airis/pkg/types"
 	"github.com/aarioai/airis/pkg/utils"
+	"go.mongodb.org/mongo-driver/v2/event"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
 	"go.mongodb.org/mongo-driver/v2/mongo/writeconcern"
+	"regexp"
 	"strings"
-	"sync"
 	"time"
 )
 
@@ -27,9 +29,9 @@ type MongodbConnectionOptions struct {
 	ReplicaSet             string        `json:"replica_set" query:"replicaSet"`
 	ServerSelectionTimeout time.Duration `json:"server_selection_timeout" query:"serverSelectionTimeoutMS"`
 	Timeout                time.Duration `json:"timeout" query:"timeoutMS"`
-	//Tls                    bool          `json:"tls" query:"tls"`
-	WriteConcern        string `json:"write_concern" query:"w"`
-	WriteConcernJournal bool   `json:"write_concern_journal" query:"j"`
+	Tls                    bool          `json:"tls" query:"tls"`
+	WriteConcern           string        `json:"write_concern" query:"w"`
+	WriteConcernJournal    bool          `json:"write_concern_journal" query:"j"`
 }
 
 func (c *MongodbConnectionOptions) LoadTo(opts *options.ClientOptions) {
@@ -62,6 +64,9 @@ func (c *MongodbConnectionOptions) LoadTo(opts *options.ClientOptions) {
 	if c.Timeout > 0 {
 		opts.SetConnectTimeout(c.Timeout)
 	}
+	if c.Tls {
+		opts.SetTLSConfig(&tls.Config{MinVersion: tls.VersionTLS12})
+	}
 	if c.WriteConcern != "" {
 		opts.SetWriteConcern(&writeconcern.WriteConcern{
 			W:       c.WriteConcern,
@@ -89,63 +94,72 @@ type MongodbClientData struct {
 	DB     string
 }
 
-var (
-	mongodbClients sync.Map
-)
-
 // NewMongodb
 // Note: better use NewMongodbPool instead
 func NewMongodb(app *aa.App, section string) (*mongo.Client, string, *ae.Error) {
+	defer beginSecretPass()()
 	o, err := ParseMongodbConfig(app, section)
 	if err != nil {
 		return nil, "", newConfigError(section, err)
 	}
-	opts := o.ClientOptions()
-	var client *mongo.Client
-	if client, err = mongo.Connect(opts); err != nil {
-		return nil, "", NewMongodbError(err, "connect to mongodb instance "+o.Hosts)
+	client, e := connectMongodb(o, section, nil)
+	if e != nil {
+		return nil, "", e
 	}
+	loadSlowConfig(app, PoolKindMongodb, section)
+	loadRetryPolicy(app, PoolKindMongodb, section)
 	return client, o.DB, nil
 }
 
+func connectMongodb(o *MongodbOptions, section string, poolMonitor *event.PoolMonitor) (*mongo.Client, *ae.Error) {
+	opts := o.ClientOptions()
+	opts.SetMonitor(mongodbCommandMonitor(section))
+	if poolMonitor != nil {
+		opts.SetPoolMonitor(poolMonitor)
+	}
+	client, err := mongo.Connect(opts)
+	if err != nil {
+		return nil, NewMongodbError(err, "connect to mongodb instance "+o.Hosts)
+	}
+	return client, nil
+}
+
 // NewMongodbPool mongodb 自带连接池
 // Warning: Do not unset the returned client as it is managed by the pool
 // Warning: 使用完不要unset client，释放是错误人为操作，可能会导致其他正在使用该client的线程panic，这里不做过度处理。
 func NewMongodbPool(app *aa.App, section string) (*mongo.Client, string, *ae.Error) {
-	d, ok := mongodbClients.Load(section)
-	if ok {
-		clientData := d.(MongodbClientData)
-		if clientData.Client != nil {
-			return clientData.Client, clientData.DB, nil
+	d, e := loadOrNewPool(app, PoolKindMongodb, section, func() (*MongodbOptions, *ae.Error) {
+		o, err := ParseMongodbConfig(app, section)
+		if err != nil {
+			return nil, newConfigError(section, err)
 		}
-		mongodbClients.Delete(section)
-	}
-	client, db, e := NewMongodb(app, section)
+		return o, nil
+	}, func(o *MongodbOptions) (MongodbClientData, *ae.Error) {
+		client, e := connectMongodb(o, section, mongodbPoolMonitor(section))
+		if e != nil {
+			return MongodbClientData{}, e
+		}
+		return MongodbClientData{Client: client, DB: o.DB}, nil
+	}, func() {
+		loadSlowConfig(app, PoolKindMongodb, section)
+		loadRetryPolicy(app, PoolKindMongodb, section)
+	}, closeMongodbClient)
 	if e != nil {
 		return nil, "", e
 	}
-	d = MongodbClientData{
-		Client: client,
-		DB:     db,
-	}
-	d, _ = mongodbClients.LoadOrStore(section, d)
-	clientData := d.(MongodbClientData)
-	return clientData.Client, clientData.DB, nil
+	return d.Client, d.DB, nil
 }
 
 // CloseMongodbPool
 // Each process should utilize a single connection, which is managed by the main function.
 // This connection should be closed when the main function terminates.
 func CloseMongodbPool(ctx context.Context) {
-	mongodbClients.Range(func(k, v interface{}) bool {
-		clientData := v.(MongodbClientData)
-		client := clientData.Client
-		if client != nil {
-			alog.Stopf("mongodb client: %s", k)
-			return client.Disconnect(ctx) == nil
-		}
-		return true
-	})
+	alog.OnError(ClosePools(ctx, PoolKindMongodb))
+}
+
+func closeMongodbClient(ctx context.Context, section string, d MongodbClientData) error {
+	alog.Stopf("mongodb client: %s", section)
+	return d.Client.Disconnect(ctx)
 }
 
 func (c *MongodbCredential) ToCredential() options.Credential {
@@ -176,6 +190,7 @@ func (o *MongodbOptions) ClientOptions() *options.ClientOptions {
 	return opts
 }
 func ParseMongodbConfig(app *aa.App, section string) (*MongodbOptions, error) {
+	defer beginSecretPass()()
 	hosts, err := tryGetSectionCfg(app, "mongodb", section, "hosts")
 	if err != nil {
 		return nil, err
@@ -238,11 +253,18 @@ func ParseMongodbConfig(app *aa.App, section string) (*MongodbOptions, error) {
 	replicaSet, _ := tryGetSectionCfg(app, "mongodb", section, "replica_set")
 	serverSelectionTimeout, _ := tryGetSectionCfg(app, "mongodb", section, "server_selection_timeout")
 	timeout, _ := tryGetSectionCfg(app, "mongodb", section, "timeout")
-	tls, _ := tryGetSectionCfg(app, "mongodb", section, "tls")
-	writerConcern, _ := tryGetSectionCfg(app, "mongodb", section, "writer_concern")
-	writeConcernJournal, _ := tryGetSectionCfg(app, "mongodb", section, "writer_concern_journal")
+	enableTLS, _ := tryGetSectionCfg(app, "mongodb", section, "tls")
+	writeConcern, _ := tryGetSectionCfg(app, "mongodb", section, "write_concern")
+	if writeConcern == "" {
+		// 兼容旧配置，已更名为 write_concern
+		writeConcern, _ = tryGetSectionCfg(app, "mongodb", section, "writer_concern")
+	}
+	writeConcernJournal, _ := tryGetSectionCfg(app, "mongodb", section, "write_concern_journal")
+	if writeConcernJournal == "" {
+		writeConcernJournal, _ = tryGetSectionCfg(app, "mongodb", section, "writer_concern_journal")
+	}
 	noOpts := allEmpty(connectTimeout, direct, heartbeatFrequency, maxIdleTime, maxPoolSize, minPoolSize)
-	noOpts = noOpts && allEmpty(replicaSet, serverSelectionTimeout, timeout, tls, writerConcern, writeConcernJournal)
+	noOpts = noOpts && allEmpty(replicaSet, serverSelectionTimeout, timeout, enableTLS, writeConcern, writeConcernJournal)
 	if !noOpts {
 		opts = &MongodbConnectionOptions{
 			ConnectTimeout:         types.ParseDuration(connectTimeout),
@@ -254,7 +276,8 @@ func ParseMongodbConfig(app *aa.App, section string) (*MongodbOptions, error) {
 			ReplicaSet:             replicaSet,
 			ServerSelectionTimeout: types.ParseDuration(serverSelectionTimeout),
 			Timeout:                types.ParseDuration(timeout),
-			WriteConcern:           writerConcern,
+			Tls:                    types.ToBool(enableTLS),
+			WriteConcern:           writeConcern,
 			WriteConcernJournal:    types.ToBool(writeConcernJournal),
 		}
 
@@ -268,13 +291,81 @@ func ParseMongodbConfig(app *aa.App, section string) (*MongodbOptions, error) {
 		ConnectionOptions: opts,
 	}, nil
 }
+
+const (
+	mongodbWriteConflictMsg     = "mongodb write conflict: "
+	mongodbTransientTxMsg       = "mongodb transient transaction error: "
+	mongodbUnknownCommitMsg     = "mongodb unknown transaction commit result: "
+	mongodbTimeoutMsg           = "mongodb timeout: "
+	mongodbNotWritableMsg       = "mongodb not writable primary: "
+	mongodbNetworkMsg           = "mongodb network error: "
+	mongodbAuthMsg              = "mongodb auth failed: "
+	mongodbTransientTxLabel     = "TransientTransactionError"
+	mongodbUnknownCommitLabel   = "UnknownTransactionCommitResult"
+	mongodbErrWriteConflict     = 112
+	mongodbErrUnauthorized      = 13
+	mongodbErrAuthFailed        = 18
+	mongodbErrShutdown          = 91
+	mongodbErrSteppedDown       = 189
+	mongodbErrNotWritable       = 10107
+	mongodbErrNotPrimaryNoSlave = 13435
+	mongodbErrInterrupted       = 11600
+)
+
+var mongodbDuplicateIndexPattern = regexp.MustCompile(`index:\s+(\S+)`)
+
+// mongodbNotWritableCodes 主节点切换或关闭中，可重试
+var mongodbNotWritableCodes = []int{mongodbErrShutdown, mongodbErrSteppedDown, mongodbErrNotWritable, mongodbErrNotPrimaryNoSlave, mongodbErrInterrupted}
+
+// classifyMongodbError returns the ae code of mongodb server, timeout and network errors, false if it's not one of them.
+// Transaction labels come first, as a write conflict or network error in a transaction should retry the transaction.
+//   - TransientTransactionError, write conflict: ae.Locked, retryable
+//   - UnknownTransactionCommitResult, not writable primary: ae.ServiceUnavailable, retryable
+//   - timeout, max time exceeded: ae.GatewayTimeout, retryable
+//   - network error: ae.BadGateway, the write may have been applied
+//   - auth failed: ae.VariantAlsoNegotiates as config errors
+func classifyMongodbError(err error) (int, string, bool) {
+	var le mongo.LabeledError
+	if errors.As(err, &le) {
+		if le.HasErrorLabel(mongodbUnknownCommitLabel) {
+			return ae.ServiceUnavailable, mongodbUnknownCommitMsg, true
+		}
+		if le.HasErrorLabel(mongodbTransientTxLabel) {
+			return ae.Locked, mongodbTransientTxMsg, true
+		}
+	}
+	var se mongo.ServerError
+	if errors.As(err, &se) {
+		if se.HasErrorCode(mongodbErrWriteConflict) {
+			return ae.Locked, mongodbWriteConflictMsg, true
+		}
+		for _, code := range mongodbNotWritableCodes {
+			if se.HasErrorCode(code) {
+				return ae.ServiceUnavailable, mongodbNotWritableMsg, true
+			}
+		}
+		if se.HasErrorCode(mongodbErrUnauthorized) || se.HasErrorCode(mongodbErrAuthFailed) {
+			return ae.VariantAlsoNegotiates, mongodbAuthMsg, true
+		}
+	}
+	if mongo.IsTimeout(err) {
+		return ae.GatewayTimeout, mongodbTimeoutMsg, true
+	}
+	if mongo.IsNetworkError(err) {
+		return ae.BadGateway, mongodbNetworkMsg, true
+	}
+	return 0, "", false
+}
+
+// NewMongodbError 处理 MongoDB 错误，IsRetryable 可判断是否可重试
 func NewMongodbError(err error, details ...any) *ae.Error {
 	if err == nil {
 		return nil
 	}
 
-	msg := err.Error()
+	msg := Redact(err.Error())
 	caller := utils.Caller(1)
+	details = redactDetails(details)
 
 	errorMapping := map[error]func() *ae.Error{
 		mongo.ErrClientDisconnected: func() *ae.Error { return ae.NewError(caller + sqlBadConnMsg + msg).WithDetail(details...) },
@@ -289,5 +380,16 @@ func NewMongodbError(err error, details ...any) *ae.Error {
 		}
 	}
 
-	return ae.NewErr(err).WithDetail(details...)
+	// 重复键，同 MySQL 处理为 conflict
+	if mongo.IsDuplicateKeyError(err) {
+		if matches := mongodbDuplicateIndexPattern.FindStringSubmatch(msg); len(matches) == 2 {
+			return ae.NewConflict("mongodb key").WithDetail(append([]any{"duplicate key for index '" + matches[1] + "'; "}, details...)...)
+		}
+		return ae.NewConflict("mongodb key").WithDetail(details...)
+	}
+	if code, prefix, ok := classifyMongodbError(err); ok {
+		return ae.New(code, caller+" "+prefix+msg).WithDetail(details...)
+	}
+
+	return ae.NewErr(RedactError(err)).WithDetail(details...)
 }
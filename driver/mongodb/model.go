@@ -15,13 +15,26 @@ type Model struct {
 	app     *aa.App
 	section string
 	loc     *time.Location
+	error   *ae.Error
 }
 
 func NewDB(app *aa.App, section string) *Model {
 	return &Model{app: app, section: section, loc: app.Config.TimeLocation}
 }
 
+// ModelFromContext returns the Model of the tenant in ctx (see driver.TenantSection), section is the default section
+func ModelFromContext(ctx context.Context, app *aa.App, section string) *Model {
+	s, e := driver.TenantSection(ctx, app, driver.PoolKindMongodb, section)
+	m := NewDB(app, s)
+	m.error = e
+	return m
+}
+
+// DB looks up the pooled client on every call, so it follows the new client after the section is hot reloaded
 func (m *Model) DB() (*mongo.Client, *mongo.Database, *ae.Error) {
+	if m.error != nil {
+		return nil, nil, m.error
+	}
 	client, db, e := driver.NewMongodbPool(m.app, m.section)
 	if e != nil {
 		return nil, nil, e
@@ -34,7 +47,9 @@ func (m *Model) InsertMany(ctx context.Context, ts []index.Entity, opts ...optio
 	if e != nil {
 		return nil, e
 	}
-	return InsertMany(ctx, db, ts, opts...)
+	return driver.Retry(ctx, m.retryPolicy(ctx, true), func(ctx context.Context) (*mongo.InsertManyResult, *ae.Error) {
+		return InsertMany(ctx, db, ts, opts...)
+	})
 }
 
 func (m *Model) ORM(t index.Entity) *ORMS {
@@ -42,5 +57,5 @@ func (m *Model) ORM(t index.Entity) *ORMS {
 	if e != nil {
 		return ErrorORM(e)
 	}
-	return ORM(db, t)
+	return ORM(db, t).WithRetry(driver.SectionRetryPolicy(driver.PoolKindMongodb, m.section))
 }
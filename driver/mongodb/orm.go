@@ -1,6 +1,7 @@
 package mongodb
 
 import (
+	"github.com/aarioai/airis-driver/driver"
 	"github.com/aarioai/airis-driver/driver/index"
 	"github.com/aarioai/airis-driver/driver/mongodb/bson2"
 	"github.com/aarioai/airis-driver/driver/mongodb/bson3"
@@ -31,6 +32,7 @@ type ORMS struct {
 	sort       bson.D
 	offset     int64
 	limit      int64
+	retry      driver.RetryPolicy
 	error      *ae.Error
 }
 
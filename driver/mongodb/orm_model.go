@@ -2,6 +2,7 @@ package mongodb
 
 import (
 	"context"
+	"github.com/aarioai/airis-driver/driver"
 	"github.com/aarioai/airis/aa/ae"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
@@ -11,7 +12,9 @@ func (o *ORMS) CreateIndexes(ctx context.Context) *ae.Error {
 	if o.error != nil {
 		return o.error
 	}
-	return CreateIndexes(ctx, o.db, o.entity)
+	return o.retryPolicy(ctx, false).Do(ctx, func(ctx context.Context) *ae.Error {
+		return CreateIndexes(ctx, o.db, o.entity)
+	})
 }
 
 func (o *ORMS) AggregateRaw(ctx context.Context, pipeline any, opts ...options.Lister[options.AggregateOptions]) (
@@ -19,42 +22,54 @@ func (o *ORMS) AggregateRaw(ctx context.Context, pipeline any, opts ...options.L
 	if o.error != nil {
 		return nil, o.error
 	}
-	return AggregateRaw(ctx, o.db, o.entity, pipeline, opts...)
+	return driver.Retry(ctx, o.retryPolicy(ctx, false), func(ctx context.Context) (*mongo.Cursor, *ae.Error) {
+		return AggregateRaw(ctx, o.db, o.entity, pipeline, opts...)
+	})
 }
 
 func (o *ORMS) Aggregate(ctx context.Context, results any, pipeline any, opts ...options.Lister[options.AggregateOptions]) *ae.Error {
 	if o.error != nil {
 		return o.error
 	}
-	return Aggregate(ctx, results, o.db, o.entity, pipeline, opts...)
+	return o.retryPolicy(ctx, false).Do(ctx, func(ctx context.Context) *ae.Error {
+		return Aggregate(ctx, results, o.db, o.entity, pipeline, opts...)
+	})
 }
 
 func (o *ORMS) CountDocuments(ctx context.Context, opts ...options.Lister[options.CountOptions]) (int64, *ae.Error) {
 	if o.error != nil {
 		return 0, o.error
 	}
-	return CountDocuments(ctx, o.db, o.entity, o.Filter(), opts...)
+	return driver.Retry(ctx, o.retryPolicy(ctx, false), func(ctx context.Context) (int64, *ae.Error) {
+		return CountDocuments(ctx, o.db, o.entity, o.Filter(), opts...)
+	})
 }
 
 func (o *ORMS) DeleteOne(ctx context.Context, opts ...options.Lister[options.DeleteOneOptions]) (*mongo.DeleteResult, *ae.Error) {
 	if o.error != nil {
 		return nil, o.error
 	}
-	return DeleteOne(ctx, o.db, o.entity, o.Filter(), opts...)
+	return driver.Retry(ctx, o.retryPolicy(ctx, true), func(ctx context.Context) (*mongo.DeleteResult, *ae.Error) {
+		return DeleteOne(ctx, o.db, o.entity, o.Filter(), opts...)
+	})
 }
 
 func (o *ORMS) DeleteMany(ctx context.Context, opts ...options.Lister[options.DeleteManyOptions]) (*mongo.DeleteResult, *ae.Error) {
 	if o.error != nil {
 		return nil, o.error
 	}
-	return DeleteMany(ctx, o.db, o.entity, o.Filter(), opts...)
+	return driver.Retry(ctx, o.retryPolicy(ctx, true), func(ctx context.Context) (*mongo.DeleteResult, *ae.Error) {
+		return DeleteMany(ctx, o.db, o.entity, o.Filter(), opts...)
+	})
 }
 
 func (o *ORMS) Distinct(ctx context.Context, field string, opts ...options.Lister[options.DistinctOptions]) (*mongo.DistinctResult, *ae.Error) {
 	if o.error != nil {
 		return nil, o.error
 	}
-	return Distinct(ctx, o.db, o.entity, field, o.Filter(), opts...)
+	return driver.Retry(ctx, o.retryPolicy(ctx, false), func(ctx context.Context) (*mongo.DistinctResult, *ae.Error) {
+		return Distinct(ctx, o.db, o.entity, field, o.Filter(), opts...)
+	})
 }
 
 func (o *ORMS) Drop(ctx context.Context, opts ...options.Lister[options.DropCollectionOptions]) *ae.Error {
@@ -68,7 +83,9 @@ func (o *ORMS) EstimatedDocumentCount(ctx context.Context, opts ...options.Liste
 	if o.error != nil {
 		return 0, o.error
 	}
-	return EstimatedDocumentCount(ctx, o.db, o.entity, opts...)
+	return driver.Retry(ctx, o.retryPolicy(ctx, false), func(ctx context.Context) (int64, *ae.Error) {
+		return EstimatedDocumentCount(ctx, o.db, o.entity, opts...)
+	})
 }
 
 func (o *ORMS) findOneOptions(opts ...options.Lister[options.FindOneOptions]) []options.Lister[options.FindOneOptions] {
@@ -90,7 +107,9 @@ func (o *ORMS) FindOneRaw(ctx context.Context, opts ...options.Lister[options.Fi
 		return nil, o.error
 	}
 	opts = o.findOneOptions(opts...)
-	return FindOneRaw(ctx, o.db, o.entity, o.Filter(), opts...)
+	return driver.Retry(ctx, o.retryPolicy(ctx, false), func(ctx context.Context) (*mongo.SingleResult, *ae.Error) {
+		return FindOneRaw(ctx, o.db, o.entity, o.Filter(), opts...)
+	})
 }
 
 func (o *ORMS) FindOne(ctx context.Context, result any, opts ...options.Lister[options.FindOneOptions]) *ae.Error {
@@ -98,7 +117,9 @@ func (o *ORMS) FindOne(ctx context.Context, result any, opts ...options.Lister[o
 		return o.error
 	}
 	opts = o.findOneOptions(opts...)
-	return FindOne(ctx, result, o.db, o.entity, o.Filter(), opts...)
+	return o.retryPolicy(ctx, false).Do(ctx, func(ctx context.Context) *ae.Error {
+		return FindOne(ctx, result, o.db, o.entity, o.Filter(), opts...)
+	})
 }
 
 func (o *ORMS) findOptions(opts ...options.Lister[options.FindOptions]) []options.Lister[options.FindOptions] {
@@ -123,7 +144,9 @@ func (o *ORMS) FindManyRaw(ctx context.Context, opts ...options.Lister[options.F
 		return nil, o.error
 	}
 	opts = o.findOptions(opts...)
-	return FindManyRaw(ctx, o.db, o.entity, o.Filter(), opts...)
+	return driver.Retry(ctx, o.retryPolicy(ctx, false), func(ctx context.Context) (*mongo.Cursor, *ae.Error) {
+		return FindManyRaw(ctx, o.db, o.entity, o.Filter(), opts...)
+	})
 }
 
 func (o *ORMS) FindMany(ctx context.Context, result any, opts ...options.Lister[options.FindOptions]) *ae.Error {
@@ -131,7 +154,9 @@ func (o *ORMS) FindMany(ctx context.Context, result any, opts ...options.Lister[
 		return o.error
 	}
 	opts = o.findOptions(opts...)
-	return FindMany(ctx, result, o.db, o.entity, o.Filter(), opts...)
+	return o.retryPolicy(ctx, false).Do(ctx, func(ctx context.Context) *ae.Error {
+		return FindMany(ctx, result, o.db, o.entity, o.Filter(), opts...)
+	})
 }
 
 func (o *ORMS) findOneAndDeleteOptions(opts ...options.Lister[options.FindOneAndDeleteOptions]) []options.Lister[options.FindOneAndDeleteOptions] {
@@ -150,7 +175,9 @@ func (o *ORMS) FindOneAndDelete(ctx context.Context, opts ...options.Lister[opti
 		return nil, o.error
 	}
 	opts = o.findOneAndDeleteOptions(opts...)
-	return FindOneAndDelete(ctx, o.db, o.entity, o.Filter(), opts...)
+	return driver.Retry(ctx, o.retryPolicy(ctx, true), func(ctx context.Context) (*mongo.SingleResult, *ae.Error) {
+		return FindOneAndDelete(ctx, o.db, o.entity, o.Filter(), opts...)
+	})
 }
 
 func (o *ORMS) findOneAndReplaceOptions(opts ...options.Lister[options.FindOneAndReplaceOptions]) []options.Lister[options.FindOneAndReplaceOptions] {
@@ -169,7 +196,9 @@ func (o *ORMS) FindOneAndReplace(ctx context.Context, opts ...options.Lister[opt
 		return nil, o.error
 	}
 	opts = o.findOneAndReplaceOptions(opts...)
-	return FindOneAndReplace(ctx, o.db, o.entity, o.Filter(), opts...)
+	return driver.Retry(ctx, o.retryPolicy(ctx, true), func(ctx context.Context) (*mongo.SingleResult, *ae.Error) {
+		return FindOneAndReplace(ctx, o.db, o.entity, o.Filter(), opts...)
+	})
 }
 
 func (o *ORMS) findOneAndUpdateOptions(opts ...options.Lister[options.FindOneAndUpdateOptions]) []options.Lister[options.FindOneAndUpdateOptions] {
@@ -188,14 +217,18 @@ func (o *ORMS) FindOneAndUpdate(ctx context.Context, opts ...options.Lister[opti
 		return nil, o.error
 	}
 	opts = o.findOneAndUpdateOptions(opts...)
-	return FindOneAndUpdate(ctx, o.db, o.entity, o.Filter(), o.update, opts...)
+	return driver.Retry(ctx, o.retryPolicy(ctx, true), func(ctx context.Context) (*mongo.SingleResult, *ae.Error) {
+		return FindOneAndUpdate(ctx, o.db, o.entity, o.Filter(), o.update, opts...)
+	})
 }
 
 func (o *ORMS) Insert(ctx context.Context, opts ...options.Lister[options.InsertOneOptions]) (*mongo.InsertOneResult, *ae.Error) {
 	if o.error != nil {
 		return nil, o.error
 	}
-	return InsertOne(ctx, o.db, o.entity, opts...)
+	return driver.Retry(ctx, o.retryPolicy(ctx, true), func(ctx context.Context) (*mongo.InsertOneResult, *ae.Error) {
+		return InsertOne(ctx, o.db, o.entity, opts...)
+	})
 }
 
 func (o *ORMS) replaceOptions(opts ...options.Lister[options.ReplaceOptions]) []options.Lister[options.ReplaceOptions] {
@@ -214,7 +247,9 @@ func (o *ORMS) ReplaceOne(ctx context.Context, opts ...options.Lister[options.Re
 		return nil, o.error
 	}
 	opts = o.replaceOptions(opts...)
-	return ReplaceOne(ctx, o.db, o.entity, o.Filter(), opts...)
+	return driver.Retry(ctx, o.retryPolicy(ctx, true), func(ctx context.Context) (*mongo.UpdateResult, *ae.Error) {
+		return ReplaceOne(ctx, o.db, o.entity, o.Filter(), opts...)
+	})
 }
 
 func (o *ORMS) updateOneOptions(opts ...options.Lister[options.UpdateOneOptions]) []options.Lister[options.UpdateOneOptions] {
@@ -233,14 +268,18 @@ func (o *ORMS) UpdateOne(ctx context.Context, update any, opts ...options.Lister
 		return nil, o.error
 	}
 	opts = o.updateOneOptions(opts...)
-	return UpdateOne(ctx, o.db, o.entity, o.Filter(), update, opts...)
+	return driver.Retry(ctx, o.retryPolicy(ctx, true), func(ctx context.Context) (*mongo.UpdateResult, *ae.Error) {
+		return UpdateOne(ctx, o.db, o.entity, o.Filter(), update, opts...)
+	})
 }
 
 func (o *ORMS) UpdateMany(ctx context.Context, update any, opts ...options.Lister[options.UpdateManyOptions]) (*mongo.UpdateResult, *ae.Error) {
 	if o.error != nil {
 		return nil, o.error
 	}
-	return UpdateMany(ctx, o.db, o.entity, o.Filter(), update, opts...)
+	return driver.Retry(ctx, o.retryPolicy(ctx, true), func(ctx context.Context) (*mongo.UpdateResult, *ae.Error) {
+		return UpdateMany(ctx, o.db, o.entity, o.Filter(), update, opts...)
+	})
 }
 
 func (o *ORMS) UpsertOne(ctx context.Context, update any, opts ...options.Lister[options.UpdateOneOptions]) (*mongo.UpdateResult, *ae.Error) {
@@ -248,14 +287,18 @@ func (o *ORMS) UpsertOne(ctx context.Context, update any, opts ...options.Lister
 		return nil, o.error
 	}
 	o.updateOneOptions(opts...)
-	return UpsertOne(ctx, o.db, o.entity, o.Filter(), update, opts...)
+	return driver.Retry(ctx, o.retryPolicy(ctx, true), func(ctx context.Context) (*mongo.UpdateResult, *ae.Error) {
+		return UpsertOne(ctx, o.db, o.entity, o.Filter(), update, opts...)
+	})
 }
 
 func (o *ORMS) UpsertMany(ctx context.Context, update any, opts ...options.Lister[options.UpdateManyOptions]) (*mongo.UpdateResult, *ae.Error) {
 	if o.error != nil {
 		return nil, o.error
 	}
-	return UpsertMany(ctx, o.db, o.entity, o.Filter(), update, opts...)
+	return driver.Retry(ctx, o.retryPolicy(ctx, true), func(ctx context.Context) (*mongo.UpdateResult, *ae.Error) {
+		return UpsertMany(ctx, o.db, o.entity, o.Filter(), update, opts...)
+	})
 }
 
 func (o *ORMS) InsertOrUpdate(ctx context.Context, opts ...options.Lister[options.UpdateOneOptions]) (*mongo.UpdateResult, *ae.Error) {
@@ -263,5 +306,7 @@ func (o *ORMS) InsertOrUpdate(ctx context.Context, opts ...options.Lister[option
 		return nil, o.error
 	}
 	opts = o.updateOneOptions(opts...)
-	return InsertOrUpdate(ctx, o.db, o.entity, opts...)
+	return driver.Retry(ctx, o.retryPolicy(ctx, true), func(ctx context.Context) (*mongo.UpdateResult, *ae.Error) {
+		return InsertOrUpdate(ctx, o.db, o.entity, opts...)
+	})
 }
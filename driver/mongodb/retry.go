@@ -0,0 +1,26 @@
+package mongodb
+
+import (
+	"context"
+	"github.com/aarioai/airis-driver/driver"
+)
+
+// WithRetry sets the retry policy, Model.ORM uses the one of the section. Writes are retried only with an idempotent ctx
+func (o *ORMS) WithRetry(p driver.RetryPolicy) *ORMS {
+	o.retry = p
+	return o
+}
+
+func (o *ORMS) retryPolicy(ctx context.Context, write bool) driver.RetryPolicy {
+	if write && !driver.IsIdempotent(ctx) {
+		return driver.RetryPolicy{}
+	}
+	return o.retry
+}
+
+func (m *Model) retryPolicy(ctx context.Context, write bool) driver.RetryPolicy {
+	if write && !driver.IsIdempotent(ctx) {
+		return driver.RetryPolicy{}
+	}
+	return driver.SectionRetryPolicy(driver.PoolKindMongodb, m.section)
+}
@@ -0,0 +1,44 @@
+package driver_test
+
+import (
+	"fmt"
+	"github.com/aarioai/airis-driver/driver"
+	"github.com/aarioai/airis/aa/ae"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"strings"
+	"testing"
+)
+
+func TestNewMongodbError(t *testing.T) {
+	dup := mongo.WriteException{WriteErrors: mongo.WriteErrors{{
+		Code:    11000,
+		Message: `E11000 duplicate key error collection: test.users index: email_1 dup key: { email: "root@luexu.com" }`,
+	}}}
+	e := driver.NewMongodbError(fmt.Errorf("insert: %w", dup), "users")
+	if e.Code != ae.Conflict || !strings.Contains(e.Detail, "index 'email_1'") || driver.IsRetryable(e) {
+		t.Errorf("duplicate key: %+v", e)
+	}
+
+	cases := []struct {
+		err       error
+		code      int
+		retryable bool
+	}{
+		{mongo.CommandError{Code: 112, Name: "WriteConflict"}, ae.Locked, true},
+		{mongo.CommandError{Code: 251, Name: "NoSuchTransaction", Labels: []string{"TransientTransactionError"}}, ae.Locked, true},
+		{mongo.CommandError{Code: 50, Name: "MaxTimeMSExpired", Labels: []string{"UnknownTransactionCommitResult"}}, ae.ServiceUnavailable, true},
+		{mongo.CommandError{Code: 50, Name: "MaxTimeMSExpired"}, ae.GatewayTimeout, true},
+		{mongo.CommandError{Code: 10107, Name: "NotWritablePrimary"}, ae.ServiceUnavailable, true},
+		{mongo.CommandError{Message: "connection reset", Labels: []string{"NetworkError"}}, ae.BadGateway, false},
+		{mongo.CommandError{Code: 18, Name: "AuthenticationFailed"}, ae.VariantAlsoNegotiates, false},
+	}
+	for _, c := range cases {
+		e := driver.NewMongodbError(c.err)
+		if e.Code != c.code {
+			t.Errorf("%s: code %d, want %d", c.err, e.Code, c.code)
+		}
+		if driver.IsRetryable(e) != c.retryable || driver.IsRetryable(c.err) != c.retryable {
+			t.Errorf("%s: retryable should be %v", c.err, c.retryable)
+		}
+	}
+}
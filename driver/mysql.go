@@ -1,12 +1,14 @@
 package driver
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"database/sql"
-	"database/sql/driver"
 	"errors"
 	"fmt"
+	"os"
 	"regexp"
-	"sync"
 	"time"
 
 	"github.com/aarioai/airis/aa"
@@ -15,28 +17,26 @@ import (
 	"github.com/aarioai/airis/aa/atype"
 	"github.com/aarioai/airis/pkg/types"
 	"github.com/aarioai/airis/pkg/utils"
-	_ "github.com/go-sql-driver/mysql" // 需要引入
+	"github.com/go-sql-driver/mysql"
 )
 
+// MySQL server error numbers, see https://dev.mysql.com/doc/mysql-errors/8.0/en/server-error-reference.html
 const (
-	sqlBadConnMsg   = "sql bad conn: "
-	sqlSkipMsg      = "sql skip: "
-	sqlRemoveArgMsg = "sql remove argument: "
-	sqlConnDoneMsg  = "sql conn done: "
-	sqlTxDoneMsg    = "sql tx done: "
-	sqlErrorMsg     = "sql error: "
+	mysqlErrDupEntry        = 1062
+	mysqlErrNoSuchTable     = 1146
+	mysqlErrLockWaitTimeout = 1205
+	mysqlErrDeadlock        = 1213
+	mysqlErrDataTooLong     = 1406
+	mysqlErrRowIsReferenced = 1451
+	mysqlErrNoReferencedRow = 1452
 )
 
 var (
 	duplicateKeyPattern = regexp.MustCompile(`Duplicate\s+entry\s+'([^']*)'\s+for\s+key\s+'([^']*)'`)
 )
 
-type MysqlPoolOptions struct {
-	MaxIdleConns    int
-	MaxOpenConns    int
-	ConnMaxLifetime time.Duration
-	ConnMaxIdleTime time.Duration
-}
+// MysqlPoolOptions is kept for compatibility
+type MysqlPoolOptions = SqlPoolOptions
 
 // https://github.com/go-sql-driver/mysql/
 type MysqlOptions struct {
@@ -45,8 +45,28 @@ type MysqlOptions struct {
 	Password string
 	// Scheme   string // tcp|unix，只支持tcp，unix仅本地可用
 	TLS  string // 默认 false，Valid Values:   true, false, skip-verify, preferred, <name>
-	Host string
-	// Charset  string  不建议用，应该服务器默认设置
+	Host string // 可逗号分隔多个，连接失败时按 HostPolicy 切换，如 db1:3306,db2:3306
+	// ordered (默认) 或 random，总是先尝试上次成功的 host
+	HostPolicy string
+
+	// 配置了 tls_ca、tls_cert 或 tls_server_name 后，以 TLSName 注册自定义 tls config，TLS 为 true/skip-verify/preferred 决定校验方式
+	TLSName       string // airis_$section
+	TLSCA         string // CA 证书文件
+	TLSCert       string // 客户端证书文件，需同时配置 TLSKey
+	TLSKey        string
+	TLSServerName string // 默认为 host 的主机名；多个 host 时证书只能校验一个名字，启用 tls 必须配置
+
+	Charset              string         // 不建议用，应该服务器默认设置；可逗号分隔多个，如 utf8mb4,utf8
+	Collation            string         // 如 utf8mb4_unicode_ci
+	ParseTime            bool           // DATE/DATETIME 解析为 time.Time
+	Loc                  *time.Location // ParseTime 及时间参数的时区，默认 app.Config.TimeLocation
+	InterpolateParams    bool           // 在客户端替换占位符，减少 prepare 往返
+	MaxAllowedPacket     int            // 0 使用 driver 默认值 64MiB
+	MultiStatements      bool
+	ConnectionAttributes string // 逗号分隔的 key:value，如 app:user-service,env:prod
+
+	// 每个新连接执行的语句，见 ParseMysqlSessionInit 和 AddMysqlSessionInit
+	SessionInit []string
 
 	// mysql客户端在尝试与mysql服务器建立连接时，mysql服务器返回错误握手协议前等待客户端数据包的最大时限。默认10秒。
 	ConnectTimeout time.Duration // 使用时，需要设置单位，s, ms等。Timeout for establishing connections, aka dial timeout
@@ -56,107 +76,188 @@ type MysqlOptions struct {
 	Pool MysqlPoolOptions
 }
 
-type MysqlClientData struct {
-	Client *sql.DB
-	Schema string
-}
+// MysqlClientData is kept for compatibility
+type MysqlClientData = SqlClientData
 
-var (
-	mysqlClients sync.Map
-)
-
-// NewMysqlDSN parse Mysql Data Source Name from configuration
+// NewMysqlDSN parse Mysql Data Source Name from configuration, the custom tls config (if any) is registered
 func NewMysqlDSN(app *aa.App, section string) (string, MysqlOptions, *ae.Error) {
 	f, err := ParseMysqlConfig(app, section)
 	if err != nil {
 		return "", f, newConfigError(section, err)
 	}
-	ct := f.ConnectTimeout.Seconds()
-	rt := f.ReadTimeout.Seconds()
-	wt := f.WriteTimeout.Seconds()
+	if err = f.RegisterTLS(); err != nil {
+		return "", f, newConfigError(section, err)
+	}
 	alog.Printf("connect mysql: %s@%s %s", f.User, f.Host, f.Schema)
-	return fmt.Sprintf("%s:%s@tcp(%s)/%s?timeout=%.1fs&readTimeout=%.1fs&writeTimeout=%.1fs", f.User, f.Password, f.Host, f.Schema, ct, rt, wt), f, nil
+	return f.DSN(), f, nil
+}
+
+// Config converts to go-sql-driver config
+func (f MysqlOptions) Config() *mysql.Config {
+	c := mysql.NewConfig()
+	c.User = f.User
+	c.Passwd = f.Password
+	c.Net = "tcp"
+	// 多个 host 时，DSN 只含第一个，由 openMysql 设置 DialFunc 切换
+	if hosts := f.Hosts(); len(hosts) > 0 {
+		c.Addr = hosts[0]
+	}
+	c.DBName = f.Schema
+	c.TLSConfig = f.TLS
+	if f.customTLS() {
+		c.TLSConfig = f.TLSName
+		c.AllowFallbackToPlaintext = f.TLS == "preferred"
+	}
+	if f.Charset != "" {
+		_ = c.Apply(mysql.Charset(f.Charset, f.Collation))
+	} else {
+		c.Collation = f.Collation
+	}
+	c.ParseTime = f.ParseTime
+	if f.Loc != nil {
+		c.Loc = f.Loc
+	}
+	c.InterpolateParams = f.InterpolateParams
+	if f.MaxAllowedPacket > 0 {
+		c.MaxAllowedPacket = f.MaxAllowedPacket
+	}
+	c.MultiStatements = f.MultiStatements
+	c.ConnectionAttributes = f.ConnectionAttributes
+	c.Timeout = f.ConnectTimeout
+	c.ReadTimeout = f.ReadTimeout
+	c.WriteTimeout = f.WriteTimeout
+	return c
+}
+
+// DSN Mysql Data Source Name
+func (f MysqlOptions) DSN() string {
+	return f.Config().FormatDSN()
+}
+
+func (f MysqlOptions) customTLS() bool {
+	return f.TLSName != ""
+}
+
+// checkMultiHostTLS 多个 host 共用一个 tls config，按 Addr（第一个 host）校验证书会在切换后失败，所以需要 tls_server_name
+func (f MysqlOptions) checkMultiHostTLS() error {
+	if len(f.Hosts()) < 2 || f.TLSServerName != "" {
+		return nil
+	}
+	// skip-verify 不校验主机名；未配置 tls 但配置了 tls_ca/tls_cert 时会校验
+	verify := f.TLS == "true" || f.TLS == "preferred" || (f.TLS == "" && (f.TLSCA != "" || f.TLSCert != ""))
+	if !verify {
+		return nil
+	}
+	return errors.New("tls with multiple hosts requires tls_server_name")
+}
+
+// RegisterTLS registers the custom tls config built from TLSCA, TLSCert and TLSKey, it's re-registered on reload
+func (f MysqlOptions) RegisterTLS() error {
+	if !f.customTLS() {
+		return nil
+	}
+	c := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		// 为空时 driver 使用 Addr 的主机名
+		ServerName:         f.TLSServerName,
+		InsecureSkipVerify: f.TLS == "skip-verify",
+	}
+	if f.TLSCA != "" {
+		pem, err := os.ReadFile(f.TLSCA)
+		if err != nil {
+			return fmt.Errorf("tls_ca: %w", err)
+		}
+		c.RootCAs = x509.NewCertPool()
+		if !c.RootCAs.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("tls_ca: no certificate found in %s", f.TLSCA)
+		}
+	}
+	if f.TLSCert != "" {
+		cert, err := tls.LoadX509KeyPair(f.TLSCert, f.TLSKey)
+		if err != nil {
+			return fmt.Errorf("tls_cert: %w", err)
+		}
+		c.Certificates = []tls.Certificate{cert}
+	}
+	return mysql.RegisterTLSConfig(f.TLSName, c)
 }
 
 // NewMysql
 // Note: better use NewMysqlPool instead
 func NewMysql(app *aa.App, section string) (string, *sql.DB, *ae.Error) {
-	dsn, f, e := NewMysqlDSN(app, section)
+	f, err := ParseMysqlConfig(app, section)
+	if err != nil {
+		return "", nil, newConfigError(section, err)
+	}
+	db, e := openMysql(section, f)
 	if e != nil {
 		return "", nil, e
 	}
+	return f.Schema, db, nil
+}
+
+func openMysql(section string, f MysqlOptions) (*sql.DB, *ae.Error) {
+	alog.Printf("connect mysql: %s@%s %s", f.User, f.Host, f.Schema)
+	if err := f.RegisterTLS(); err != nil {
+		return nil, ae.NewF(ae.VariantAlsoNegotiates, "mysql tls config %s error: %s", f.TLSName, err.Error())
+	}
 	// sqlx.Open并不会立即建立一个数据库的网络连接, 也不会对数据库链接参数的合法性做检验, 它仅仅是初始化一个sql.DB对象. 当真正进行第一次数据库查询操作时, 此时才会真正建立网络连接;
 	// sqlx.Open返回的sql.DB对象是协程并发安全的.
 	// sqlx.DB表示操作数据库的抽象接口的对象，但不是所谓的数据库连接对象，sqlx.DB对象只有当需要使用时才会创建连接，如果想立即验证连接，需要用Ping()方法;
 	// 每次db.Query操作后, 都建议调用rows.Close(). 因为 db.Query() 会从数据库连接池中获取一个连接, 这个底层连接在结果集(rows)未关闭前会被标记为处于繁忙状态。当遍历读到最后一条记录时，会发生一个内部EOF错误，自动调用rows.Close(),但如果提前退出循环，rows不会关闭，连接不会回到连接池中，连接也不会关闭, 则此连接会一直被占用. 因此通常我们使用 defer rows.Close() 来确保数据库连接可以正确放回到连接池中; 不过阅读源码发现rows.Close()操作是幂等操作，即一个幂等操作的特点是其任意多次执行所产生的影响均与一次执行的影响相同, 所以即便对已关闭的rows再执行close()也没关系.
-	// 需要import 	_ "github.com/go-sqlx-driver/mysql"
-	conn, err := sql.Open("mysql", dsn)
+	// 通过 Connector 打开，以便每个新连接执行 SessionInit
+	cfg := f.Config()
+	if hosts := f.Hosts(); len(hosts) > 1 {
+		cfg.DialFunc = mysqlFailoverDialerOf(section, f).dial
+		// 每个 host 各自的连接超时
+		cfg.Timeout = f.ConnectTimeout * time.Duration(len(hosts))
+	}
+	connector, err := mysql.NewConnector(cfg)
 	if err != nil {
-		return "", conn, NewMysqlError(err, fmt.Sprintf("open mysql: %s", dsn))
+		return nil, NewMysqlError(err, "open mysql: "+Redact(f.DSN()))
 	}
-
-	// It is rare to Close a db, as the db handle is meant to be long-lived and shared between many goroutines.
-	conn.SetMaxIdleConns(f.Pool.MaxIdleConns) // 设置闲置的连接数
-	conn.SetMaxOpenConns(f.Pool.MaxOpenConns) // 设置最大打开的连接数，默认值为0表示不限制
-	conn.SetConnMaxLifetime(f.Pool.ConnMaxLifetime)
-	conn.SetConnMaxIdleTime(f.Pool.ConnMaxIdleTime)
-	return f.Schema, conn, nil
+	conn := sql.OpenDB(newMysqlConnector(connector, section, f.SessionInit))
+	f.Pool.apply(conn)
+	return conn, nil
 }
 
 // NewMysqlPool
 // Warning: Do not unset the returned client as it is managed by the pool
 // Warning: 使用完不要unset client，释放是错误人为操作，可能会导致其他正在使用该client的线程panic，这里不做过度处理。
 func NewMysqlPool(app *aa.App, section string) (string, *sql.DB, *ae.Error) {
-	d, ok := mysqlClients.Load(section)
-	if ok {
-		clientData := d.(MysqlClientData)
-		if clientData.Client != nil {
-			return clientData.Schema, clientData.Client, nil
+	d, e := loadOrNewPool(app, PoolKindMysql, section, func() (MysqlOptions, *ae.Error) {
+		f, err := ParseMysqlConfig(app, section)
+		if err != nil {
+			return f, newConfigError(section, err)
 		}
-		mysqlClients.Delete(section)
-	}
-	schema, db, e := NewMysql(app, section)
+		return f, nil
+	}, func(f MysqlOptions) (SqlClientData, *ae.Error) {
+		db, e := openMysql(section, f)
+		if e != nil {
+			return SqlClientData{}, e
+		}
+		return SqlClientData{Client: db, Schema: f.Schema}, nil
+	}, func() {
+		loadSlowConfig(app, PoolKindMysql, section)
+		loadRetryPolicy(app, PoolKindMysql, section)
+		loadBreaker(app, PoolKindMysql, section)
+		loadKeepalive(app, PoolKindMysql, section)
+	}, closeSqlClient(PoolKindMysql))
 	if e != nil {
 		return "", nil, e
 	}
-	mysqlClients.LoadOrStore(section, MysqlClientData{
-		Schema: schema,
-		Client: db,
-	})
-	return schema, db, nil
+	return d.Schema, d.Client, nil
 }
 
 // CloseMysqlPool
 // Each process should utilize a single connection, which is managed by the main function.
 // This connection should be closed when the main function terminates.
 func CloseMysqlPool() {
-	mysqlClients.Range(func(k, v interface{}) bool {
-		clientData := v.(MysqlClientData)
-		client := clientData.Client
-		if client != nil {
-			alog.Stopf("mysql client: %s", k)
-			return client.Close() == nil
-		}
-		return true
-	})
-}
-
-// func keepalive(bo *aa.App) {
-// 	tick := time.NewTicker(60 * time.Second)
-// 	var err error
-// 	for {
-// 		select {
-// 		case <-tick.C:
-// 			if err = mysqlPool1.Ping(); err != nil {
-// 				mysqlPool1.Close()
-// 				mysqlPool1, err = connectMysql1(bo)
-// 			}
-// 		}
-// 		runtime.Gosched()
-// 	}
-// }
+	alog.OnError(ClosePools(context.Background(), PoolKindMysql))
+}
 
 func ParseMysqlConfig(app *aa.App, section string) (MysqlOptions, error) {
+	defer beginSecretPass()()
 	host, err := tryGetSectionCfg(app, "mysql", section, "host")
 	if err != nil {
 		return MysqlOptions{}, err
@@ -175,62 +276,132 @@ func ParseMysqlConfig(app *aa.App, section string) (MysqlOptions, error) {
 		return MysqlOptions{}, err
 	}
 
+	hostPolicy, _ := tryGetSectionCfg(app, "mysql", section, "host_policy", MysqlHostOrdered)
 	tls, _ := tryGetSectionCfg(app, "mysql", section, "tls")
+	tlsCA, _ := tryGetSectionCfg(app, "mysql", section, "tls_ca")
+	tlsCert, _ := tryGetSectionCfg(app, "mysql", section, "tls_cert")
+	tlsKey, _ := tryGetSectionCfg(app, "mysql", section, "tls_key")
+	tlsServerName, _ := tryGetSectionCfg(app, "mysql", section, "tls_server_name")
+	charset, _ := tryGetSectionCfg(app, "mysql", section, "charset")
+	collation, _ := tryGetSectionCfg(app, "mysql", section, "collation")
+	parseTime, _ := tryGetSectionCfg(app, "mysql", section, "parse_time")
+	locName, _ := tryGetSectionCfg(app, "mysql", section, "loc")
+	interpolateParams, _ := tryGetSectionCfg(app, "mysql", section, "interpolate_params")
+	maxAllowedPacket, _ := tryGetSectionCfg(app, "mysql", section, "max_allowed_packet")
+	multiStatements, _ := tryGetSectionCfg(app, "mysql", section, "multi_statements")
+	connectionAttributes, _ := tryGetSectionCfg(app, "mysql", section, "connection_attributes")
+	loc := app.Config.TimeLocation
+	if locName != "" {
+		if loc, err = time.LoadLocation(locName); err != nil {
+			return MysqlOptions{}, fmt.Errorf("%s.loc: %w", section, err)
+		}
+	}
 	timeout, _ := tryGetSectionCfg(app, "mysql", section, "timeout")
 	ct, rt, wt := ParseTimeouts(timeout)
-	poolMaxIdleConns, _ := tryGetSectionCfg(app, "mysql", section, "pool_max_idle_conns")
-	poolMaxOpenConns, _ := tryGetSectionCfg(app, "mysql", section, "pool_max_open_conns")
-	poolConnMaxLifetime, _ := tryGetSectionCfg(app, "mysql", section, "pool_conn_max_life_time")
-	poolConnMaxIdleTime, _ := tryGetSectionCfg(app, "mysql", section, "pool_conn_max_idle_time")
 
 	newV := atype.New()
 	defer newV.Close()
 
 	cf := MysqlOptions{
-		Schema:         schema,
-		User:           user,
-		Password:       password,
-		TLS:            tls,
-		Host:           host,
-		ConnectTimeout: ct,
-		ReadTimeout:    rt,
-		WriteTimeout:   wt,
-		Pool: MysqlPoolOptions{
-			MaxIdleConns:    newV.Reload(poolMaxIdleConns).DefaultInt(0),
-			MaxOpenConns:    newV.Reload(poolMaxOpenConns).DefaultInt(0),
-			ConnMaxLifetime: types.ParseDuration(poolConnMaxLifetime),
-			ConnMaxIdleTime: types.ParseDuration(poolConnMaxIdleTime),
-		},
+		Schema:               schema,
+		User:                 user,
+		Password:             password,
+		TLS:                  tls,
+		TLSCA:                tlsCA,
+		TLSCert:              tlsCert,
+		TLSKey:               tlsKey,
+		TLSServerName:        tlsServerName,
+		Host:                 host,
+		HostPolicy:           hostPolicy,
+		Charset:              charset,
+		Collation:            collation,
+		ParseTime:            types.ToBool(parseTime),
+		Loc:                  loc,
+		InterpolateParams:    types.ToBool(interpolateParams),
+		MaxAllowedPacket:     newV.Reload(maxAllowedPacket).DefaultInt(0),
+		MultiStatements:      types.ToBool(multiStatements),
+		ConnectionAttributes: connectionAttributes,
+		SessionInit:          ParseMysqlSessionInit(app, section),
+		ConnectTimeout:       ct,
+		ReadTimeout:          rt,
+		WriteTimeout:         wt,
+		Pool:                 ParseSqlPoolOptions(app, "mysql", section),
+	}
+	if err = cf.checkMultiHostTLS(); err != nil {
+		return MysqlOptions{}, fmt.Errorf("%s: %w", section, err)
+	}
+	if (tlsCA != "" || tlsCert != "" || tlsServerName != "" && tls != "") && tls != "false" {
+		cf.TLSName = "airis_" + section
 	}
 	return cf, nil
 }
 
-func NewMysqlError(err error, details ...any) *ae.Error {
-	if err == nil {
-		return nil
-	}
-	msg := err.Error()
-	caller := utils.Caller(1)
+const (
+	MysqlReplicaRoundRobin = "round_robin"
+	MysqlReplicaLeastConn  = "least_conn" // the replica with the fewest in-use connections
+)
 
-	errorMapping := map[error]func() *ae.Error{
-		driver.ErrBadConn:        func() *ae.Error { return ae.NewError(caller + sqlBadConnMsg + msg).WithDetail(details...) },
-		driver.ErrSkip:           func() *ae.Error { return ae.NewError(caller + sqlSkipMsg + msg).WithDetail(details...) },
-		driver.ErrRemoveArgument: func() *ae.Error { return ae.NewError(caller + sqlRemoveArgMsg + msg).WithDetail(details...) },
-		sql.ErrNoRows:            func() *ae.Error { return ae.ErrorNotFound }, // can't WithDetail, locked
-		sql.ErrConnDone:          func() *ae.Error { return ae.NewError(caller + sqlConnDoneMsg + msg).WithDetail(details...) },
-		sql.ErrTxDone:            func() *ae.Error { return ae.NewError(caller + sqlTxDoneMsg + msg).WithDetail(details...) },
-	}
+// MysqlReplicas read replicas of a primary section, e.g. replicas = mysql_r1,mysql_r2
+type MysqlReplicas struct {
+	Sections []string
+	Policy   string // round_robin (default) or least_conn
+}
 
-	for errType, handler := range errorMapping {
-		if errors.Is(err, errType) {
-			return handler()
+// ParseMysqlReplicas reads replicas and replica_policy of the section itself, they are not inherited from [mysql], or
+// every section (including the replicas) would route to the same replicas
+func ParseMysqlReplicas(app *aa.App, section string) MysqlReplicas {
+	if section == "" {
+		section = "mysql"
+	}
+	r := MysqlReplicas{Policy: MysqlReplicaRoundRobin}
+	for _, s := range []string{section, "mysql_" + section} {
+		replicas, err := app.Config.MustGetString(s + ".replicas")
+		if err != nil {
+			continue
+		}
+		r.Sections = parseStrings(replicas, ",")
+		if policy, _ := app.Config.MustGetString(s + ".replica_policy"); policy != "" {
+			r.Policy = policy
 		}
+		break
 	}
+	return r
+}
+
+// NewMysqlError is NewSqlError, the name is kept for compatibility
+func NewMysqlError(err error, details ...any) *ae.Error {
+	return newSqlError(utils.Caller(1), err, details)
+}
 
-	// 处理重复键错误
-	if matches := duplicateKeyPattern.FindStringSubmatch(msg); len(matches) == 3 {
+func duplicateKeyDetail(matches []string) string {
+	return fmt.Sprintf("duplicate entry '%s' for key '%s'; ", matches[1], matches[2])
+}
+
+// newMysqlNumberError maps the MySQL server error number to ae code. Only deadlock and lock wait timeout are retryable.
+func newMysqlNumberError(me *mysql.MySQLError, caller, msg string, details []any) *ae.Error {
+	switch me.Number {
+	case mysqlErrDupEntry:
+		if matches := duplicateKeyPattern.FindStringSubmatch(msg); len(matches) == 3 {
+			return ae.NewConflict("sql key").WithDetail(append([]any{duplicateKeyDetail(matches)}, details...)...)
+		}
 		return ae.NewConflict("sql key").WithDetail(details...)
+	case mysqlErrDeadlock:
+		return ae.New(ae.Locked, caller+sqlDeadlockMsg+msg).WithDetail(details...)
+	case mysqlErrLockWaitTimeout:
+		return ae.New(ae.Locked, caller+sqlLockWaitTimeoutMsg+msg).WithDetail(details...)
+	case mysqlErrRowIsReferenced:
+		return ae.New(ae.Conflict, caller+sqlRowReferencedMsg+msg).WithDetail(details...)
+	case mysqlErrNoReferencedRow:
+		return ae.New(ae.FailedDependency, caller+sqlNoReferencedRowMsg+msg).WithDetail(details...)
+	case mysqlErrDataTooLong:
+		return ae.New(ae.RequestEntityTooLarge, caller+sqlDataTooLongMsg+msg).WithDetail(details...)
+	case mysqlErrNoSuchTable:
+		return ae.New(ae.InternalServerError, caller+sqlNoSuchTableMsg+msg).WithDetail(details...)
 	}
+	return nil
+}
 
-	return ae.NewError(caller + sqlErrorMsg + msg).WithDetail(details...)
+// newMysqlLostConnError 连接断开时语句可能已经执行，所以是 BadGateway，而不是 IsRetryable 的 ServiceUnavailable
+func newMysqlLostConnError(caller, msg string, details []any) *ae.Error {
+	return ae.New(ae.BadGateway, caller+sqlLostConnMsg+msg).WithDetail(details...)
 }
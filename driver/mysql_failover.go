@@ -0,0 +1,123 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aarioai/airis/aa/alog"
+)
+
+const (
+	MysqlHostOrdered = "ordered" // try the hosts in the configured order
+	MysqlHostRandom  = "random"  // try the hosts in random order, to spread the connections
+)
+
+// Hosts returns the endpoints of Host (comma separated), with the default port 3306
+func (f MysqlOptions) Hosts() []string {
+	hosts := parseStrings(f.Host, ",")
+	for i, h := range hosts {
+		if _, _, err := net.SplitHostPort(h); err != nil {
+			hosts[i] = net.JoinHostPort(strings.Trim(h, "[]"), "3306")
+		}
+	}
+	return hosts
+}
+
+// mysqlFailoverDialer dials the hosts of a section one by one until one succeeds. The last good host is always tried
+// first, so connections stick to it until it fails, e.g. the writer endpoint of MGR moved.
+type mysqlFailoverDialer struct {
+	section string
+	hosts   []string
+	random  bool
+	timeout time.Duration // of each host
+	last    atomic.Int32  // index of the last good host, -1 if none
+}
+
+var mysqlFailoverDialers sync.Map // section -> *mysqlFailoverDialer
+
+// mysqlFailoverDialerOf returns the dialer of the section, it's kept across reloads if the hosts are not changed, so
+// a recycled pool still starts from the last good host
+func mysqlFailoverDialerOf(section string, f MysqlOptions) *mysqlFailoverDialer {
+	d := &mysqlFailoverDialer{
+		section: section,
+		hosts:   f.Hosts(),
+		random:  f.HostPolicy == MysqlHostRandom,
+		timeout: f.ConnectTimeout,
+	}
+	d.last.Store(-1)
+	if v, ok := mysqlFailoverDialers.Load(section); ok {
+		if old := v.(*mysqlFailoverDialer); slices.Equal(old.hosts, d.hosts) && old.random == d.random {
+			d.last.Store(old.last.Load())
+		}
+	}
+	mysqlFailoverDialers.Store(section, d)
+	return d
+}
+
+// MysqlActiveHost returns the last good host of a multi-host section, "" if none
+func MysqlActiveHost(section string) string {
+	v, ok := mysqlFailoverDialers.Load(section)
+	if !ok {
+		return ""
+	}
+	d := v.(*mysqlFailoverDialer)
+	if i := d.last.Load(); i >= 0 {
+		return d.hosts[i]
+	}
+	return ""
+}
+
+func (d *mysqlFailoverDialer) order() []int {
+	n := len(d.hosts)
+	order := make([]int, 0, n)
+	last := int(d.last.Load())
+	if last >= 0 {
+		order = append(order, last)
+	}
+	rest := make([]int, 0, n)
+	for i := 0; i < n; i++ {
+		if i != last {
+			rest = append(rest, i)
+		}
+	}
+	if d.random {
+		rand.Shuffle(len(rest), func(i, j int) { rest[i], rest[j] = rest[j], rest[i] })
+	}
+	return append(order, rest...)
+}
+
+// dial is mysql.Config.DialFunc, addr is ignored
+func (d *mysqlFailoverDialer) dial(ctx context.Context, network, _ string) (net.Conn, error) {
+	var errs []error
+	var dialer net.Dialer
+	for _, i := range d.order() {
+		if ctx.Err() != nil {
+			break
+		}
+		hctx, cancel := ctx, context.CancelFunc(func() {})
+		if d.timeout > 0 {
+			hctx, cancel = context.WithTimeout(ctx, d.timeout)
+		}
+		conn, err := dialer.DialContext(hctx, network, d.hosts[i])
+		cancel()
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if prev := d.last.Swap(int32(i)); prev >= 0 && prev != int32(i) {
+			alog.Printf("mysql [%s] failover: %s -> %s", d.section, d.hosts[prev], d.hosts[i])
+		}
+		return conn, nil
+	}
+	if err := ctx.Err(); err != nil {
+		errs = append(errs, err)
+	}
+	return nil, errors.Join(errs...)
+}
@@ -0,0 +1,99 @@
+package driver
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/aarioai/airis/aa"
+	"github.com/aarioai/airis/aa/alog"
+)
+
+var (
+	mysqlSessionMtx   sync.RWMutex
+	mysqlSessionInits = map[string][]string{} // section -> statements, "" for all sections
+)
+
+// AddMysqlSessionInit registers statements run on every new connection of the section ("" for all sections), after
+// the ones configured in the section. Existing connections are not affected, so call it before opening the pool.
+func AddMysqlSessionInit(section string, stmts ...string) {
+	mysqlSessionMtx.Lock()
+	defer mysqlSessionMtx.Unlock()
+	mysqlSessionInits[section] = append(mysqlSessionInits[section], stmts...)
+}
+
+func registeredMysqlSessionInits(section string) []string {
+	mysqlSessionMtx.RLock()
+	defer mysqlSessionMtx.RUnlock()
+	return slices.Concat(mysqlSessionInits[""], mysqlSessionInits[section])
+}
+
+// ParseMysqlSessionInit reads time_zone, sql_mode, transaction_isolation, optimizer_switch and init_sql (separated
+// by ;) of the section into statements
+func ParseMysqlSessionInit(app *aa.App, section string) []string {
+	var stmts []string
+	for _, v := range []struct{ key, variable string }{
+		{"time_zone", "time_zone"},
+		{"sql_mode", "sql_mode"},
+		{"transaction_isolation", "transaction_isolation"},
+		{"optimizer_switch", "optimizer_switch"},
+	} {
+		value, _ := tryGetSectionCfg(app, "mysql", section, v.key)
+		if value == "" {
+			continue
+		}
+		if v.key == "transaction_isolation" {
+			// READ COMMITTED -> READ-COMMITTED
+			value = strings.ReplaceAll(strings.ToUpper(strings.TrimSpace(value)), " ", "-")
+		}
+		stmts = append(stmts, fmt.Sprintf("SET SESSION %s = '%s'", v.variable, strings.ReplaceAll(value, "'", "''")))
+	}
+	initSQL, _ := tryGetSectionCfg(app, "mysql", section, "init_sql")
+	for _, stmt := range strings.Split(initSQL, ";") {
+		if stmt = strings.TrimSpace(stmt); stmt != "" {
+			stmts = append(stmts, stmt)
+		}
+	}
+	return stmts
+}
+
+// mysqlConnector runs the session init statements on every new connection. A failed connection is closed and the
+// error is returned to the caller (e.g. the query that needed a new connection), so no connection runs with the
+// server defaults by accident.
+type mysqlConnector struct {
+	driver.Connector
+	section string
+	stmts   []string
+}
+
+func newMysqlConnector(base driver.Connector, section string, stmts []string) driver.Connector {
+	stmts = slices.Concat(stmts, registeredMysqlSessionInits(section))
+	if len(stmts) == 0 {
+		return base
+	}
+	return &mysqlConnector{Connector: base, section: section, stmts: stmts}
+}
+
+func (c *mysqlConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	execer, ok := conn.(driver.ExecerContext)
+	if !ok {
+		_ = conn.Close()
+		return nil, errors.New("mysql session init: connection does not support exec")
+	}
+	for _, stmt := range c.stmts {
+		if _, err = execer.ExecContext(ctx, stmt, nil); err != nil {
+			_ = conn.Close()
+			alog.Printf("mysql [%s] session init %s: %s", c.section, stmt, Redact(err.Error()))
+			return nil, fmt.Errorf("mysql session init %s: %w", stmt, err)
+		}
+	}
+	return conn, nil
+}
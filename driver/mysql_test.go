@@ -1,10 +1,18 @@
 package driver_test
 
 import (
+	"context"
+	sqldriver "database/sql/driver"
+	"fmt"
 	"github.com/aarioai/airis-driver/driver"
 	"github.com/aarioai/airis/aa"
 	"github.com/aarioai/airis/aa/aconfig"
 	"github.com/aarioai/airis/aa/acontext"
+	"github.com/aarioai/airis/aa/ae"
+	"github.com/go-sql-driver/mysql"
+	"net"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 )
@@ -53,3 +61,148 @@ func testMySQLConfig(t *testing.T, app *aa.App, want string, suppose driver.Mysq
 		t.Errorf("test mysql password %d not match %d", mysqlConfig.WriteTimeout, suppose.WriteTimeout)
 	}
 }
+
+func TestMysqlDSN(t *testing.T) {
+	c, err := aconfig.New("./test_config.ini", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := acontext.WithCancel(acontext.Background())
+	app := aa.New(ctx, cancel, c)
+
+	f, err := driver.ParseMysqlConfig(app, "dsn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := f.Config()
+	if cfg.DBName != "dsn" || cfg.User != "Aario" || cfg.Passwd != "Luexu.com" || cfg.Addr != "luexu.com:3306" {
+		t.Errorf("mysql dsn address not match: %s", driver.Redact(f.DSN()))
+	}
+	if cfg.Collation != "utf8mb4_unicode_ci" || !cfg.ParseTime || cfg.Loc != time.UTC || !cfg.InterpolateParams ||
+		cfg.MaxAllowedPacket != 4194304 || cfg.ConnectionAttributes != "app:airis,env:test" {
+		t.Errorf("mysql dsn params not match: %s", driver.Redact(f.DSN()))
+	}
+	dsn := f.DSN()
+	if cfg.TLSConfig != "airis_dsn" || !strings.Contains(dsn, "tls=airis_dsn") || !strings.Contains(dsn, "writeTimeout=5s") {
+		t.Errorf("mysql dsn tls/timeout not match: %s", driver.Redact(dsn))
+	}
+	if err = f.RegisterTLS(); err == nil {
+		t.Error("register tls with missing ca file should fail")
+	}
+	if _, _, e := driver.NewMysqlDSN(app, "dsn"); e == nil {
+		t.Error("NewMysqlDSN with missing tls ca file should fail")
+	}
+
+	wantInit := []string{
+		"SET SESSION time_zone = '+08:00'",
+		"SET SESSION transaction_isolation = 'READ-COMMITTED'",
+		"SET SESSION innodb_lock_wait_timeout = 5",
+		"SET SESSION max_execution_time = 3000",
+	}
+	if !slices.Equal(f.SessionInit, wantInit) {
+		t.Errorf("mysql session init %q, want %q", f.SessionInit, wantInit)
+	}
+
+	// loc 默认为 app.Config.TimeLocation
+	if f, _ = driver.ParseMysqlConfig(app, "hello"); f.Loc != app.Config.TimeLocation {
+		t.Errorf("mysql loc %v, want %v", f.Loc, app.Config.TimeLocation)
+	}
+}
+
+func TestMysqlFailover(t *testing.T) {
+	hosts := driver.MysqlOptions{Host: "db1, db2:3307,[::1]"}.Hosts()
+	if want := []string{"db1:3306", "db2:3307", "[::1]:3306"}; !slices.Equal(hosts, want) {
+		t.Errorf("mysql hosts %v, want %v", hosts, want)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	good := ln.Addr().String()
+	c, err := aconfig.New("./test_config.ini", map[string]string{
+		"mysql_failover.host":    "127.0.0.1:1," + good,
+		"mysql_failover.timeout": "1s,1s,1s",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := acontext.WithCancel(acontext.Background())
+	app := aa.New(ctx, cancel, c)
+
+	if _, err = driver.ParseMysqlConfig(app, "mysql_failover"); err != nil {
+		t.Fatal(err)
+	}
+	tc, err := aconfig.New("./test_config.ini", map[string]string{
+		"mysql_failover.host": "db1,db2",
+		"mysql_failover.tls":  "true",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tapp := aa.New(ctx, cancel, tc)
+	if _, err = driver.ParseMysqlConfig(tapp, "mysql_failover"); err == nil {
+		t.Error("tls with multiple hosts should require tls_server_name")
+	}
+	_, db, e := driver.NewMysqlPool(app, "mysql_failover")
+	if e != nil {
+		t.Fatal(e.Msg)
+	}
+	defer driver.ClosePool(context.Background(), driver.PoolKindMysql, "mysql_failover")
+	pctx, pcancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer pcancel()
+	_ = db.PingContext(pctx) // 握手失败，但已连上第二个 host
+	if got := driver.MysqlActiveHost("mysql_failover"); got != good {
+		t.Errorf("mysql active host %q, want %q", got, good)
+	}
+}
+
+func TestNewMysqlError(t *testing.T) {
+	dup := &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'root@luexu.com' for key 'users.email'"}
+	e := driver.NewMysqlError(fmt.Errorf("insert: %w", dup), "INSERT INTO users")
+	if e.Code != ae.Conflict || !strings.Contains(e.Detail, "'root@luexu.com' for key 'users.email'") {
+		t.Errorf("duplicate entry: %+v", e)
+	}
+
+	cases := []struct {
+		number    uint16
+		code      int
+		retryable bool
+	}{
+		{1213, ae.Locked, true},
+		{1205, ae.Locked, true},
+		{1451, ae.Conflict, false},
+		{1452, ae.FailedDependency, false},
+		{1406, ae.RequestEntityTooLarge, false},
+		{1146, ae.InternalServerError, false},
+	}
+	for _, c := range cases {
+		err := &mysql.MySQLError{Number: c.number, Message: "test"}
+		e := driver.NewMysqlError(err)
+		if e.Code != c.code {
+			t.Errorf("mysql error %d code %d, want %d", c.number, e.Code, c.code)
+		}
+		if driver.IsRetryable(e) != c.retryable || driver.IsRetryable(err) != c.retryable {
+			t.Errorf("mysql error %d retryable should be %v", c.number, c.retryable)
+		}
+	}
+	if e := driver.NewMysqlError(mysql.ErrInvalidConn); e.Code != ae.BadGateway || driver.IsRetryable(e) {
+		t.Errorf("mysql invalid connection should be non-retryable bad gateway, got %v", e)
+	}
+	if e := driver.NewSqlError(sqldriver.ErrBadConn); e.Code != ae.BadGateway || driver.IsRetryable(e) {
+		t.Errorf("bad connection should be non-retryable bad gateway, got %v", e)
+	}
+	if driver.IsRetryable(nil) || driver.IsRetryable((*ae.Error)(nil)) {
+		t.Errorf("nil should not be retryable")
+	}
+}
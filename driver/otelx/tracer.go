@@ -0,0 +1,72 @@
+package otelx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aarioai/airis-driver/driver"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracer adapts an OpenTelemetry tracer to driver.Tracer
+//
+//	driver.SetTracer(otelx.NewTracer(otel.Tracer("airis-driver")))
+type Tracer struct {
+	tracer trace.Tracer
+}
+
+func NewTracer(tracer trace.Tracer) *Tracer {
+	return &Tracer{tracer: tracer}
+}
+
+func (t *Tracer) Start(ctx context.Context, op driver.TraceOp) (context.Context, driver.Span) {
+	attrs := []attribute.KeyValue{
+		attribute.String("airis.section", op.Section),
+	}
+	if op.Kind == driver.TraceKindHttp {
+		attrs = append(attrs, attribute.String("http.request.method", op.Name), attribute.String("url.full", op.Statement))
+	} else {
+		attrs = append(attrs, attribute.String("db.system", op.Kind), attribute.String("db.operation.name", op.Name), attribute.String("db.query.text", op.Statement))
+	}
+	ctx, s := t.tracer.Start(ctx, op.Kind+" "+op.Name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+	return ctx, span{span: s}
+}
+
+type span struct {
+	span trace.Span
+}
+
+func (s span) SetAttribute(key string, value any) {
+	var kv attribute.KeyValue
+	switch v := value.(type) {
+	case string:
+		kv = attribute.String(key, v)
+	case bool:
+		kv = attribute.Bool(key, v)
+	case int:
+		kv = attribute.Int(key, v)
+	case int64:
+		kv = attribute.Int64(key, v)
+	case float64:
+		kv = attribute.Float64(key, v)
+	case time.Duration:
+		kv = attribute.String(key, v.String())
+	default:
+		kv = attribute.String(key, fmt.Sprint(v))
+	}
+	s.span.SetAttributes(kv)
+}
+
+// End records the redacted error, not found is not treated as a failure
+func (s span) End(err error) {
+	if err != nil && driver.ErrorClass(err) != "not_found" {
+		msg := driver.Redact(err.Error())
+		s.span.RecordError(errors.New(msg))
+		s.span.SetStatus(codes.Error, msg)
+	}
+	s.span.End()
+}
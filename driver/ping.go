@@ -0,0 +1,62 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/wagslane/go-rabbitmq"
+)
+
+// pingPoolClient pings a pooled client, without opening a new connection
+func pingPoolClient(ctx context.Context, client any) error {
+	switch c := client.(type) {
+	case *redis.Client:
+		return c.Ping(ctx).Err()
+	case SqlClientData:
+		return c.Client.PingContext(ctx)
+	case MongodbClientData:
+		return c.Client.Ping(ctx, nil)
+	case InfluxdbClientData:
+		ok, err := c.Client.Ping(ctx)
+		if err == nil && !ok {
+			err = errors.New("influxdb is not ready")
+		}
+		return err
+	case *rabbitmq.Conn:
+		return pingRabbitmq(ctx, c)
+	}
+	return fmt.Errorf("unsupported pool client %T", client)
+}
+
+// pingRabbitmq opens and closes a channel without declaring any queue. go-rabbitmq doesn't expose the amqp connection,
+// so the channel is opened by a publisher, in a goroutine to respect ctx
+func pingRabbitmq(ctx context.Context, c *rabbitmq.Conn) error {
+	done := make(chan error, 1)
+	go func() {
+		publisher, err := rabbitmq.NewPublisher(c)
+		if err == nil {
+			publisher.Close()
+		}
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// PingPool pings the pooled client of kind+section, bypassing the circuit breaker
+func PingPool(ctx context.Context, kind PoolKind, section string) error {
+	entry, ok := pools.load(poolKey{kind: kind, section: section})
+	if !ok {
+		return fmt.Errorf("%s [%s] is not pooled", kind, section)
+	}
+	if err := pingPoolClient(BypassBreaker(ctx), entry.client); err != nil {
+		return RedactError(err)
+	}
+	return nil
+}
@@ -0,0 +1,443 @@
+package driver
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aarioai/airis/aa"
+	"github.com/aarioai/airis/aa/ae"
+	"github.com/aarioai/airis/aa/alog"
+	"github.com/aarioai/airis/pkg/afmt"
+)
+
+// PoolKind 连接池类型，同时也是配置文件的基础 section，如 [mysql] [redis]
+type PoolKind string
+
+const (
+	PoolKindInfluxdb PoolKind = "influxdb"
+	PoolKindMongodb  PoolKind = "mongodb"
+	PoolKindMysql    PoolKind = "mysql"
+	PoolKindPostgres PoolKind = "postgres"
+	PoolKindRabbitmq PoolKind = "rabbitmq"
+	PoolKindRedis    PoolKind = "redis"
+	PoolKindSqlite   PoolKind = "sqlite"
+)
+
+// PoolKinds lists all kinds of pooled clients
+var PoolKinds = []PoolKind{PoolKindInfluxdb, PoolKindMongodb, PoolKindMysql, PoolKindPostgres, PoolKindRabbitmq, PoolKindRedis, PoolKindSqlite}
+
+// DefaultPoolDrainDelay 热更新后，旧连接延迟关闭，等待正在执行的操作完成
+const DefaultPoolDrainDelay = 30 * time.Second
+
+// PoolReloadHandler is called after a pooled client was swapped (reloaded or recycled). The client type depends on the kind:
+// *redis.Client, SqlClientData (mysql, postgres, sqlite), MongodbClientData, InfluxdbClientData, *rabbitmq.Conn
+type PoolReloadHandler func(section string, client any)
+
+type poolKey struct {
+	kind    PoolKind
+	section string
+}
+
+type poolEntry struct {
+	key    poolKey
+	ready  chan struct{} // closed after the client was built (or failed)
+	client any
+	cfgSum string
+	config any // the parsed config the client was built from
+	err    *ae.Error
+
+	sum     func() string // see configSum
+	parse   func() (any, *ae.Error)
+	connect func(config any) (any, *ae.Error)
+	closer  func(ctx context.Context, client any) error
+	apply   func() // loads the section settings (slow log, retry, breaker, keepalive) once a client is live
+}
+
+type poolRegistry struct {
+	entries   sync.Map // poolKey -> *poolEntry, lock-free on the hit path of every query
+	mtx       sync.Mutex
+	draining  map[*poolEntry]*time.Timer
+	reloadMtx sync.Mutex
+	handlers  map[PoolKind][]PoolReloadHandler
+}
+
+var (
+	pools = &poolRegistry{
+		draining: make(map[*poolEntry]*time.Timer),
+		handlers: make(map[PoolKind][]PoolReloadHandler),
+	}
+)
+
+// loadOrNewPool returns the pooled client of kind+section. Concurrent callers share a single build.
+// A failed build is not cached, the next call will try again.
+// apply (optional) is called only after the client was built, so a failed build won't change the section settings.
+func loadOrNewPool[T any, C any](app *aa.App, kind PoolKind, section string, parse func() (C, *ae.Error), connect func(C) (T, *ae.Error), apply func(), closer func(ctx context.Context, section string, client T) error) (T, *ae.Error) {
+	key := poolKey{kind: kind, section: section}
+	if v, ok := pools.entries.Load(key); ok {
+		return waitPoolEntry[T](v.(*poolEntry))
+	}
+	entry := &poolEntry{
+		key:   key,
+		ready: make(chan struct{}),
+		sum: func() string {
+			return configSum(app, kind, section)
+		},
+		parse: func() (any, *ae.Error) {
+			config, e := parse()
+			return config, e
+		},
+		connect: func(config any) (any, *ae.Error) {
+			client, e := connect(config.(C))
+			return client, e
+		},
+		closer: func(ctx context.Context, client any) error {
+			return closer(ctx, section, client.(T))
+		},
+		apply: apply,
+	}
+	if v, loaded := pools.entries.LoadOrStore(key, entry); loaded {
+		return waitPoolEntry[T](v.(*poolEntry))
+	}
+
+	defer close(entry.ready)
+	defer beginSecretPass()()
+	var client T
+	cfgSum := entry.sum()
+	config, e := parse()
+	if e == nil {
+		client, e = connect(config)
+	}
+	if e != nil {
+		entry.err = e
+		pools.remove(key, entry)
+		return client, e
+	}
+	entry.client = client
+	entry.cfgSum = cfgSum
+	entry.config = config
+	entry.applySettings()
+	return client, nil
+}
+
+// waitPoolEntry waits for the entry built by another caller
+func waitPoolEntry[T any](entry *poolEntry) (T, *ae.Error) {
+	<-entry.ready
+	if entry.err != nil {
+		var zero T
+		return zero, entry.err
+	}
+	return entry.client.(T), nil
+}
+
+// renew returns a ready entry that shares the builders of e
+func (e *poolEntry) renew(client any, cfgSum string, config any) *poolEntry {
+	entry := &poolEntry{
+		key:     e.key,
+		ready:   make(chan struct{}),
+		client:  client,
+		cfgSum:  cfgSum,
+		config:  config,
+		sum:     e.sum,
+		parse:   e.parse,
+		connect: e.connect,
+		closer:  e.closer,
+		apply:   e.apply,
+	}
+	close(entry.ready)
+	return entry
+}
+
+func (e *poolEntry) applySettings() {
+	if e.apply != nil {
+		e.apply()
+	}
+}
+
+func (e *poolEntry) close(ctx context.Context) error {
+	<-e.ready
+	if e.err != nil {
+		return nil
+	}
+	if err := e.closer(ctx, e.client); err != nil {
+		return fmt.Errorf("close %s [%s]: %w", e.key.kind, e.key.section, err)
+	}
+	return nil
+}
+
+// remove deletes the entry only if it is still the registered one
+func (r *poolRegistry) remove(key poolKey, entry *poolEntry) bool {
+	return r.entries.CompareAndDelete(key, entry)
+}
+
+// swap replaces old with entry only if old is still the registered one
+func (r *poolRegistry) swap(key poolKey, old, entry *poolEntry) bool {
+	return r.entries.CompareAndSwap(key, old, entry)
+}
+
+func (r *poolRegistry) load(key poolKey) (*poolEntry, bool) {
+	v, ok := r.entries.Load(key)
+	if !ok {
+		return nil, false
+	}
+	entry := v.(*poolEntry)
+	<-entry.ready
+	if entry.err != nil {
+		return nil, false
+	}
+	return entry, true
+}
+
+// drain closes the replaced entry after delay, so in-flight work on the old client can finish
+func (r *poolRegistry) drain(entry *poolEntry, delay time.Duration) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.draining[entry] = time.AfterFunc(delay, func() {
+		if r.undrain(entry) {
+			alog.OnError(entry.close(context.Background()))
+		}
+	})
+}
+
+func (r *poolRegistry) undrain(entry *poolEntry) bool {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	timer, ok := r.draining[entry]
+	if ok {
+		timer.Stop()
+		delete(r.draining, entry)
+	}
+	return ok
+}
+
+// takeDraining removes and returns all draining entries of this kind
+func (r *poolRegistry) takeDraining(kind PoolKind) []*poolEntry {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	var entries []*poolEntry
+	for entry, timer := range r.draining {
+		if entry.key.kind == kind {
+			timer.Stop()
+			delete(r.draining, entry)
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// PoolSections lists the sections of live pooled clients of this kind
+func PoolSections(kind PoolKind) []string {
+	var sections []string
+	pools.entries.Range(func(k, _ any) bool {
+		if key := k.(poolKey); key.kind == kind {
+			sections = append(sections, key.section)
+		}
+		return true
+	})
+	slices.Sort(sections)
+	return sections
+}
+
+// PoolConfig returns the parsed config that the pooled client was built from
+// E.g. PoolConfig[MysqlOptions](PoolKindMysql, "mysql")
+func PoolConfig[T any](kind PoolKind, section string) (T, bool) {
+	var zero T
+	entry, ok := pools.load(poolKey{kind: kind, section: section})
+	if !ok {
+		return zero, false
+	}
+	config, ok := entry.config.(T)
+	return config, ok
+}
+
+// PoolClient returns the pooled client of kind+section if it was built, it never builds a new one
+// E.g. PoolClient[*redis.Client](PoolKindRedis, "redis")
+func PoolClient[T any](kind PoolKind, section string) (T, bool) {
+	var zero T
+	entry, ok := pools.load(poolKey{kind: kind, section: section})
+	if !ok {
+		return zero, false
+	}
+	client, ok := entry.client.(T)
+	return client, ok
+}
+
+// ClosePool closes and removes a single pooled client
+func ClosePool(ctx context.Context, kind PoolKind, section string) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	key := poolKey{kind: kind, section: section}
+	v, ok := pools.entries.LoadAndDelete(key)
+	if !ok {
+		return nil
+	}
+	entry := v.(*poolEntry)
+	stopKeepalive(key)
+	return entry.close(ctx)
+}
+
+// ClosePools closes all pooled clients of this kind, including the ones still draining after reload.
+// It won't stop on failure, all errors are joined.
+func ClosePools(ctx context.Context, kind PoolKind) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var errs []error
+	for _, section := range PoolSections(kind) {
+		if err := ClosePool(ctx, kind, section); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, entry := range pools.takeDraining(kind) {
+		if err := entry.close(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// OnPoolReload registers a handler called after a pooled client of this kind is hot reloaded
+func OnPoolReload(kind PoolKind, handler PoolReloadHandler) {
+	pools.mtx.Lock()
+	defer pools.mtx.Unlock()
+	pools.handlers[kind] = append(pools.handlers[kind], handler)
+}
+
+func notifyPoolReload(kind PoolKind, section string, client any) {
+	pools.mtx.Lock()
+	handlers := slices.Clone(pools.handlers[kind])
+	pools.mtx.Unlock()
+	for _, handler := range handlers {
+		handler(section, client)
+	}
+}
+
+// ReloadPool re-reads the config section of a pooled client. If the config changed, a new client is built and swapped
+// into the pool, the old one is closed after drainDelay (default DefaultPoolDrainDelay).
+// It returns false if the section is not pooled or nothing changed.
+func ReloadPool(ctx context.Context, kind PoolKind, section string, drainDelay ...time.Duration) (bool, *ae.Error) {
+	return renewPool(ctx, kind, section, false, drainDelay...)
+}
+
+// RecyclePool rebuilds the pooled client even if the config is not changed, e.g. after the server restarted or the
+// host DNS changed. The old client is drained like ReloadPool. It returns false if the section is not pooled.
+func RecyclePool(ctx context.Context, kind PoolKind, section string, drainDelay ...time.Duration) (bool, *ae.Error) {
+	return renewPool(ctx, kind, section, true, drainDelay...)
+}
+
+func renewPool(ctx context.Context, kind PoolKind, section string, force bool, drainDelay ...time.Duration) (bool, *ae.Error) {
+	pools.reloadMtx.Lock()
+	defer pools.reloadMtx.Unlock()
+	defer beginSecretPass()()
+
+	key := poolKey{kind: kind, section: section}
+	old, ok := pools.load(key)
+	if !ok {
+		return false, nil
+	}
+	// sum before parse, so that a config changed in between is seen by the next reload
+	cfgSum := old.sum()
+	config, e := old.parse()
+	if e != nil {
+		return false, e
+	}
+	if !force && cfgSum == old.cfgSum {
+		// the live client is kept, but settings like slow_threshold may have changed
+		old.applySettings()
+		return false, nil
+	}
+	client, e := old.connect(config)
+	if e != nil {
+		return false, e
+	}
+	entry := old.renew(client, cfgSum, config)
+	if !pools.swap(key, old, entry) {
+		// closed while connecting
+		alog.OnError(entry.close(ctx))
+		return false, nil
+	}
+	entry.applySettings()
+	if force {
+		alog.Printf("recycle %s client: %s", kind, section)
+	} else {
+		alog.Printf("reload %s client: %s", kind, section)
+	}
+	notifyPoolReload(kind, section, client)
+
+	delay := afmt.First(drainDelay)
+	if delay <= 0 {
+		delay = DefaultPoolDrainDelay
+	}
+	pools.drain(old, delay)
+	return true, nil
+}
+
+// configSum hashes the resolved values of the schema keys (see configSchemas) of the section, so the reload compares
+// what is configured rather than the parsed config, which may hold pointers, e.g. *time.Location.
+// The section settings (slow log, retry, breaker, keepalive) are skipped, they are applied without rebuilding the
+// client, except the retry policy of redis which changes MaxRetries. Non-config inputs of a pool, e.g. the tls config
+// and sasl of rabbitmq, are bound to the pool when it's built and never change on reload.
+func configSum(app *aa.App, kind PoolKind, section string) string {
+	schema, ok := configSchemas[string(kind)]
+	if !ok {
+		return ""
+	}
+	h := sha256.New()
+	for _, key := range slices.Sorted(maps.Keys(schema.keys)) {
+		if isPoolSettingKey(kind, key) {
+			continue
+		}
+		var v string
+		var err error
+		if kind == PoolKindSqlite && key == "path" {
+			// 同 ParseSqliteConfig，path 不经过 ResolveSecret
+			v, err = rawSectionCfg(app, string(kind), cmp.Or(section, string(kind)), key)
+		} else {
+			v, err = tryGetSectionCfg(app, string(kind), section, key)
+		}
+		if err != nil {
+			// a missing key differs from an empty value
+			v = "\x00" + err.Error()
+		}
+		fmt.Fprintf(h, "%s=%s\n", key, strings.ReplaceAll(v, "\n", "\\n"))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func isPoolSettingKey(kind PoolKind, key string) bool {
+	if _, ok := retryCfgKeys[key]; ok {
+		return kind != PoolKindRedis
+	}
+	for _, keys := range []map[string]cfgKey{slowCfgKeys, breakerCfgKeys, keepaliveCfgKeys} {
+		if _, ok := keys[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ReloadPools reloads all pooled clients, see ReloadPool
+func ReloadPools(ctx context.Context, drainDelay ...time.Duration) error {
+	var keys []poolKey
+	pools.entries.Range(func(k, _ any) bool {
+		keys = append(keys, k.(poolKey))
+		return true
+	})
+
+	var errs []error
+	for _, key := range keys {
+		if _, e := ReloadPool(ctx, key.kind, key.section, drainDelay...); e != nil {
+			errs = append(errs, fmt.Errorf("reload %s [%s]: %s", key.kind, key.section, e.Msg))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,168 @@
+package driver_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aarioai/airis-driver/driver"
+	"github.com/aarioai/airis/aa"
+	"github.com/aarioai/airis/aa/aconfig"
+	"github.com/aarioai/airis/aa/acontext"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestPool(t *testing.T) {
+	c, err := aconfig.New("./test_config.ini", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := acontext.WithCancel(acontext.Background())
+	app := aa.New(ctx, cancel, c)
+
+	var wg sync.WaitGroup
+	clients := make([]*redis.Client, 10)
+	for i := range clients {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			client, e := driver.NewRedisPool(app, "redis_test")
+			if e != nil {
+				t.Error(e.Msg)
+				return
+			}
+			clients[i] = client
+		}(i)
+	}
+	wg.Wait()
+	for _, client := range clients[1:] {
+		if client != clients[0] {
+			t.Fatal("redis pool built more than one client")
+		}
+	}
+
+	if !slices.Contains(driver.PoolSections(driver.PoolKindRedis), "redis_test") {
+		t.Errorf("redis pool sections %v missing redis_test", driver.PoolSections(driver.PoolKindRedis))
+	}
+	opts, ok := driver.PoolConfig[*redis.Options](driver.PoolKindRedis, "redis_test")
+	if !ok || opts.DB != 1 {
+		t.Errorf("redis pool config not match: %v", opts)
+	}
+
+	if err = driver.ClosePool(context.Background(), driver.PoolKindRedis, "redis_test"); err != nil {
+		t.Error(err)
+	}
+	if slices.Contains(driver.PoolSections(driver.PoolKindRedis), "redis_test") {
+		t.Error("redis pool section redis_test not closed")
+	}
+	if _, ok = driver.PoolConfig[*redis.Options](driver.PoolKindRedis, "redis_test"); ok {
+		t.Error("closed redis pool still has config")
+	}
+}
+
+func TestReloadPool(t *testing.T) {
+	c, err := aconfig.New("./test_config.ini", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := acontext.WithCancel(acontext.Background())
+	app := aa.New(ctx, cancel, c)
+
+	old, e := driver.NewRedisPool(app, "redis_test2")
+	if e != nil {
+		t.Fatal(e.Msg)
+	}
+	defer driver.ClosePool(context.Background(), driver.PoolKindRedis, "redis_test2")
+	if ok, e := driver.ReloadPool(context.Background(), driver.PoolKindRedis, "redis_test2"); ok || e != nil {
+		t.Fatalf("reload unchanged redis pool: %v %v", ok, e)
+	}
+
+	var reloaded *redis.Client
+	driver.OnPoolReload(driver.PoolKindRedis, func(section string, client any) {
+		if section == "redis_test2" {
+			reloaded = client.(*redis.Client)
+		}
+	})
+
+	file := filepath.Join(t.TempDir(), "config.ini")
+	ini := "[redis]\naddr = luexu.com\n\n[redis_test2]\npassword = Aario\ndb = 3\n"
+	if err = os.WriteFile(file, []byte(ini), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if app.Config, err = aconfig.New(file, nil); err != nil {
+		t.Fatal(err)
+	}
+	ok, e := driver.ReloadPool(context.Background(), driver.PoolKindRedis, "redis_test2", time.Millisecond)
+	if !ok || e != nil {
+		t.Fatalf("reload changed redis pool: %v %v", ok, e)
+	}
+	client, _ := driver.NewRedisPool(app, "redis_test2")
+	if client == old || client != reloaded {
+		t.Error("redis pool client not swapped")
+	}
+	if client.Options().DB != 3 {
+		t.Errorf("reloaded redis db %d not match 3", client.Options().DB)
+	}
+}
+
+func TestPoolSettingsAfterConnect(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.ini")
+	ini := "[postgres_bad]\nhost = pg1\nuser = airis\npassword = Luexu.com\nschema = airis\nssl_mode = bogus\nretry_attempts = 3\n"
+	if err := os.WriteFile(file, []byte(ini), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := aconfig.New(file, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := acontext.WithCancel(acontext.Background())
+	app := aa.New(ctx, cancel, c)
+
+	if _, _, e := driver.NewPostgresPool(app, "postgres_bad"); e == nil {
+		t.Fatal("postgres pool with invalid ssl_mode should fail")
+	}
+	if driver.SectionRetryPolicy(driver.PoolKindPostgres, "postgres_bad").Enabled() {
+		t.Error("retry policy applied although the postgres pool failed to connect")
+	}
+}
+
+func TestReloadPoolUnchanged(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.ini")
+	ini := "[mysql_reload]\nhost = 127.0.0.1:1\nuser = Aario\npassword = Luexu.com\nloc = Asia/Shanghai\n"
+	if err := os.WriteFile(file, []byte(ini), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := aconfig.New(file, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := acontext.WithCancel(acontext.Background())
+	app := aa.New(ctx, cancel, c)
+
+	if _, _, e := driver.NewMysqlPool(app, "mysql_reload"); e != nil {
+		t.Fatal(e.Msg)
+	}
+	defer driver.ClosePool(context.Background(), driver.PoolKindMysql, "mysql_reload")
+	// *time.Location of loc is parsed again, but the config is not changed
+	if ok, e := driver.ReloadPool(context.Background(), driver.PoolKindMysql, "mysql_reload"); ok || e != nil {
+		t.Fatalf("reload unchanged mysql pool: %v %v", ok, e)
+	}
+
+	// settings like retry_attempts are applied without rebuilding the client
+	if err = os.WriteFile(file, []byte(ini+"retry_attempts = 2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if app.Config, err = aconfig.New(file, nil); err != nil {
+		t.Fatal(err)
+	}
+	if ok, e := driver.ReloadPool(context.Background(), driver.PoolKindMysql, "mysql_reload"); ok || e != nil {
+		t.Fatalf("reload mysql pool with changed settings: %v %v", ok, e)
+	}
+	if !driver.SectionRetryPolicy(driver.PoolKindMysql, "mysql_reload").Enabled() {
+		t.Error("retry policy not applied on reload")
+	}
+}
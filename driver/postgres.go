@@ -0,0 +1,210 @@
+package driver
+
+import (
+	"database/sql"
+	"errors"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aarioai/airis/aa"
+	"github.com/aarioai/airis/aa/ae"
+	"github.com/aarioai/airis/aa/alog"
+	"github.com/aarioai/airis/pkg/types"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// PostgreSQL error codes (SQLSTATE), see https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	pgErrUniqueViolation      = "23505"
+	pgErrForeignKeyViolation  = "23503"
+	pgErrStringTruncation     = "22001"
+	pgErrUndefinedTable       = "42P01"
+	pgErrDeadlock             = "40P01"
+	pgErrSerializationFailure = "40001"
+	pgErrLockNotAvailable     = "55P03"
+	pgErrAdminShutdown        = "57P01"
+)
+
+// PostgresOptions https://pkg.go.dev/github.com/jackc/pgx/v5/stdlib
+type PostgresOptions struct {
+	Schema   string // dbname
+	User     string
+	Password string
+	Host     string // 可逗号分隔多个，由 pgx 依次尝试，如 pg1:5432,pg2:5432
+	// disable (默认), allow, prefer, require, verify-ca, verify-full
+	SSLMode         string
+	SearchPath      string
+	ApplicationName string
+	ConnectTimeout  time.Duration // 每个 host 的连接超时
+
+	Pool SqlPoolOptions
+}
+
+// DSN Postgres connection URL
+func (f PostgresOptions) DSN() string {
+	hosts := parseStrings(f.Host, ",")
+	for i, h := range hosts {
+		if _, _, err := net.SplitHostPort(h); err != nil {
+			hosts[i] = net.JoinHostPort(strings.Trim(h, "[]"), "5432")
+		}
+	}
+	q := url.Values{}
+	q.Set("sslmode", f.SSLMode)
+	if f.SearchPath != "" {
+		q.Set("search_path", f.SearchPath)
+	}
+	if f.ApplicationName != "" {
+		q.Set("application_name", f.ApplicationName)
+	}
+	if f.ConnectTimeout > 0 {
+		// 仅整数秒，Config 使用精确值
+		q.Set("connect_timeout", strconv.Itoa(max(1, int(f.ConnectTimeout.Seconds()))))
+	}
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(f.User, f.Password),
+		Host:     strings.Join(hosts, ","),
+		Path:     "/" + f.Schema,
+		RawQuery: q.Encode(),
+	}
+	return u.String()
+}
+
+// Config converts to pgx config
+func (f PostgresOptions) Config() (*pgx.ConnConfig, error) {
+	c, err := pgx.ParseConfig(f.DSN())
+	if err != nil {
+		return nil, err
+	}
+	if f.ConnectTimeout > 0 {
+		// 作用于每个 host
+		c.ConnectTimeout = f.ConnectTimeout
+	}
+	return c, nil
+}
+
+// NewPostgres
+// Note: better use NewPostgresPool instead
+func NewPostgres(app *aa.App, section string) (string, *sql.DB, *ae.Error) {
+	f, err := ParsePostgresConfig(app, section)
+	if err != nil {
+		return "", nil, newConfigError(section, err)
+	}
+	db, e := openPostgres(f)
+	if e != nil {
+		return "", nil, e
+	}
+	return f.Schema, db, nil
+}
+
+func openPostgres(f PostgresOptions) (*sql.DB, *ae.Error) {
+	alog.Printf("connect postgres: %s@%s %s", f.User, f.Host, f.Schema)
+	c, err := f.Config()
+	if err != nil {
+		return nil, ae.NewF(ae.VariantAlsoNegotiates, "postgres config error: %s", Redact(err.Error()))
+	}
+	db := stdlib.OpenDB(*c)
+	f.Pool.apply(db)
+	return db, nil
+}
+
+// NewPostgresPool
+// Warning: Do not unset the returned client as it is managed by the pool
+func NewPostgresPool(app *aa.App, section string) (string, *sql.DB, *ae.Error) {
+	d, e := loadOrNewPool(app, PoolKindPostgres, section, func() (PostgresOptions, *ae.Error) {
+		f, err := ParsePostgresConfig(app, section)
+		if err != nil {
+			return f, newConfigError(section, err)
+		}
+		return f, nil
+	}, func(f PostgresOptions) (SqlClientData, *ae.Error) {
+		db, e := openPostgres(f)
+		if e != nil {
+			return SqlClientData{}, e
+		}
+		return SqlClientData{Client: db, Schema: f.Schema}, nil
+	}, func() {
+		loadSlowConfig(app, PoolKindPostgres, section)
+		loadRetryPolicy(app, PoolKindPostgres, section)
+		loadBreaker(app, PoolKindPostgres, section)
+		loadKeepalive(app, PoolKindPostgres, section)
+	}, closeSqlClient(PoolKindPostgres))
+	if e != nil {
+		return "", nil, e
+	}
+	return d.Schema, d.Client, nil
+}
+
+func ParsePostgresConfig(app *aa.App, section string) (PostgresOptions, error) {
+	defer beginSecretPass()()
+	host, err := tryGetSectionCfg(app, "postgres", section, "host")
+	if err != nil {
+		return PostgresOptions{}, err
+	}
+	schema, err := tryGetSectionCfg(app, "postgres", section, "schema")
+	if err != nil {
+		// schema 如果不存在，那么就跟section保持一致
+		schema = section
+	}
+	user, err := tryGetSectionCfg(app, "postgres", section, "user")
+	if err != nil {
+		return PostgresOptions{}, err
+	}
+	password, err := tryGetSectionCfg(app, "postgres", section, "password")
+	if err != nil {
+		return PostgresOptions{}, err
+	}
+	sslMode, _ := tryGetSectionCfg(app, "postgres", section, "ssl_mode", "disable")
+	searchPath, _ := tryGetSectionCfg(app, "postgres", section, "search_path")
+	applicationName, _ := tryGetSectionCfg(app, "postgres", section, "application_name")
+	connectTimeout, _ := tryGetSectionCfg(app, "postgres", section, "connect_timeout")
+	return PostgresOptions{
+		Schema:          schema,
+		User:            user,
+		Password:        password,
+		Host:            host,
+		SSLMode:         sslMode,
+		SearchPath:      searchPath,
+		ApplicationName: applicationName,
+		ConnectTimeout:  types.ParseDuration(connectTimeout),
+		Pool:            ParseSqlPoolOptions(app, "postgres", section),
+	}, nil
+}
+
+// newPostgresCodeError maps the SQLSTATE to ae code, like newMysqlNumberError
+func newPostgresCodeError(pe *pgconn.PgError, caller, msg string, details []any) *ae.Error {
+	switch pe.Code {
+	case pgErrUniqueViolation:
+		return ae.NewConflict("sql key").WithDetail(append([]any{Redact(pe.Detail)}, details...)...)
+	case pgErrDeadlock:
+		return ae.New(ae.Locked, caller+sqlDeadlockMsg+msg).WithDetail(details...)
+	case pgErrLockNotAvailable, pgErrSerializationFailure:
+		return ae.New(ae.Locked, caller+sqlLockWaitTimeoutMsg+msg).WithDetail(details...)
+	case pgErrForeignKeyViolation:
+		// update or delete on table "a" violates foreign key constraint ... on table "b"
+		if strings.HasPrefix(pe.Message, "update or delete") {
+			return ae.New(ae.Conflict, caller+sqlRowReferencedMsg+msg).WithDetail(details...)
+		}
+		return ae.New(ae.FailedDependency, caller+sqlNoReferencedRowMsg+msg).WithDetail(details...)
+	case pgErrStringTruncation:
+		return ae.New(ae.RequestEntityTooLarge, caller+sqlDataTooLongMsg+msg).WithDetail(details...)
+	case pgErrUndefinedTable:
+		return ae.New(ae.InternalServerError, caller+sqlNoSuchTableMsg+msg).WithDetail(details...)
+	case pgErrAdminShutdown:
+		return ae.New(ae.BadGateway, caller+sqlLostConnMsg+msg).WithDetail(details...)
+	}
+	return nil
+}
+
+func isRetryablePostgresError(err error) bool {
+	var pe *pgconn.PgError
+	if !errors.As(err, &pe) {
+		return false
+	}
+	return pe.Code == pgErrDeadlock || pe.Code == pgErrLockNotAvailable || pe.Code == pgErrSerializationFailure
+}
@@ -1,15 +1,16 @@
 package driver
 
 import (
+	"context"
 	"crypto/tls"
-	"fmt"
 	"github.com/aarioai/airis/aa"
 	"github.com/aarioai/airis/aa/ae"
 	"github.com/aarioai/airis/aa/alog"
 	"github.com/aarioai/airis/pkg/types"
 	"github.com/rabbitmq/amqp091-go"
 	"github.com/wagslane/go-rabbitmq"
-	"sync"
+	"net/url"
+	"slices"
 	"time"
 )
 
@@ -22,10 +23,6 @@ type RabbitmqConfig struct {
 	Password string
 }
 
-var (
-	rabbitmqClients sync.Map
-)
-
 // NewRabbitmq
 // Note: better use NewRabbitmqPool instead
 func NewRabbitmq(app *aa.App, section string, tlsConfig *tls.Config, sasl []amqp091.Authentication, opts []func(*rabbitmq.ConnectionOptions)) (*rabbitmq.Conn, *ae.Error) {
@@ -33,7 +30,10 @@ func NewRabbitmq(app *aa.App, section string, tlsConfig *tls.Config, sasl []amqp
 	if err != nil {
 		return nil, newConfigError(section, err)
 	}
+	return connectRabbitmq(app, c, opts)
+}
 
+func connectRabbitmq(app *aa.App, c RabbitmqConfig, opts []func(*rabbitmq.ConnectionOptions)) (*rabbitmq.Conn, *ae.Error) {
 	defaultOpts := []func(*rabbitmq.ConnectionOptions){
 		rabbitmq.WithConnectionOptionsLogger(NewRabbitMQLogger(app.Log)),
 		rabbitmq.WithConnectionOptionsConfig(c.ConnectionOptions),
@@ -56,37 +56,31 @@ func NewRabbitmq(app *aa.App, section string, tlsConfig *tls.Config, sasl []amqp
 // Warning: Do not unset the returned client as it is managed by the pool
 // Warning: 使用完不要unset client，释放是错误人为操作，可能会导致其他正在使用该client的线程panic，这里不做过度处理。
 func NewRabbitmqPool(app *aa.App, section string, tlsConfig *tls.Config, sasl []amqp091.Authentication, opts []func(*rabbitmq.ConnectionOptions)) (*rabbitmq.Conn, *ae.Error) {
-	client, ok := rabbitmqClients.Load(section)
-	if ok {
-		if client != nil {
-			return client.(*rabbitmq.Conn), nil
+	return loadOrNewPool(app, PoolKindRabbitmq, section, func() (RabbitmqConfig, *ae.Error) {
+		c, err := ParseRabbitmqConfig(app, section, tlsConfig, sasl)
+		if err != nil {
+			return c, newConfigError(section, err)
 		}
-		rabbitmqClients.Delete(section)
-	}
-	var e *ae.Error
-	client, e = NewRabbitmq(app, section, tlsConfig, sasl, opts)
-	if e != nil {
-		return nil, e
-	}
-	client, _ = rabbitmqClients.LoadOrStore(section, client)
-	return client.(*rabbitmq.Conn), nil
+		return c, nil
+	}, func(c RabbitmqConfig) (*rabbitmq.Conn, *ae.Error) {
+		return connectRabbitmq(app, c, withRabbitmqMetrics(section, slices.Clone(opts)))
+	}, nil, closeRabbitmqClient)
 }
 
 // CloseRabbitmqPool
 // Each process should utilize a single connection, which is managed by the main function.
 // This connection should be closed when the main function terminates.
 func CloseRabbitmqPool() {
-	rabbitmqClients.Range(func(k, v interface{}) bool {
-		client := v.(*rabbitmq.Conn)
-		if client != nil {
-			alog.Stopf("rabbitmq client: %s", k)
-			return client.Close() == nil
-		}
-		return true
-	})
+	alog.OnError(ClosePools(context.Background(), PoolKindRabbitmq))
+}
+
+func closeRabbitmqClient(ctx context.Context, section string, conn *rabbitmq.Conn) error {
+	alog.Stopf("rabbitmq client: %s", section)
+	return conn.Close()
 }
 
 func ParseRabbitmqConfig(app *aa.App, section string, tlsConfig *tls.Config, sasl []amqp091.Authentication) (RabbitmqConfig, error) {
+	defer beginSecretPass()()
 	host, err1 := tryGetSectionCfg(app, "rabbitmq", section, "host")
 	user, err2 := tryGetSectionCfg(app, "rabbitmq", section, "user")
 	password, err3 := tryGetSectionCfg(app, "rabbitmq", section, "password")
@@ -98,6 +92,10 @@ func ParseRabbitmqConfig(app *aa.App, section string, tlsConfig *tls.Config, sas
 	frameSize, _ := tryGetSectionCfg(app, "rabbitmq", section, "frame_size")
 	heartbeat, _ := tryGetSectionCfg(app, "rabbitmq", section, "heartbeat")
 	reconnectInterval, _ := tryGetSectionCfg(app, "rabbitmq", section, "reconnect_interval")
+	enableTLS, _ := tryGetSectionCfg(app, "rabbitmq", section, "tls") // url 为 amqps:// 时为 true
+	if tlsConfig == nil && types.ToBool(enableTLS) {
+		tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
 
 	if vhost == "" {
 		vhost = "/"
@@ -123,11 +121,17 @@ func ParseRabbitmqConfig(app *aa.App, section string, tlsConfig *tls.Config, sas
 	return c, nil
 }
 func (c RabbitmqConfig) Url() string {
-	return fmt.Sprintf("amqp://%s:%s@%s%s", c.User, c.Password, c.Host, c.ConnectionOptions.Vhost)
+	scheme := "amqp"
+	if c.ConnectionOptions.TLSClientConfig != nil {
+		scheme = "amqps"
+	}
+	// vhost 由 ConnectionOptions.Vhost 指定
+	u := url.URL{Scheme: scheme, User: url.UserPassword(c.User, c.Password), Host: c.Host}
+	return u.String()
 }
 func NewRabbitmqError(err error, details ...any) *ae.Error {
 	if err == nil {
 		return nil
 	}
-	return ae.NewErr(err, details...)
+	return ae.NewErr(RedactError(err), redactDetails(details)...)
 }
@@ -2,6 +2,7 @@ package driver
 
 import (
 	"context"
+	"fmt"
 	"github.com/aarioai/airis/aa/alog"
 	"github.com/wagslane/go-rabbitmq"
 )
@@ -17,17 +18,17 @@ func NewRabbitMQLogger(logInterface alog.LogInterface) rabbitmq.Logger {
 }
 
 func (l *RabbitMQLogger) Fatalf(msg string, args ...any) {
-	l.log.Fatalf(context.Background(), msg, args...)
+	l.log.Fatalf(context.Background(), "%s", Redact(fmt.Sprintf(msg, args...)))
 }
 func (l *RabbitMQLogger) Errorf(msg string, args ...any) {
-	l.log.Errorf(context.Background(), msg, args...)
+	l.log.Errorf(context.Background(), "%s", Redact(fmt.Sprintf(msg, args...)))
 }
 func (l *RabbitMQLogger) Warnf(msg string, args ...any) {
-	l.log.Warnf(context.Background(), msg, args...)
+	l.log.Warnf(context.Background(), "%s", Redact(fmt.Sprintf(msg, args...)))
 }
 func (l *RabbitMQLogger) Infof(msg string, args ...any) {
-	l.log.Infof(context.Background(), msg, args...)
+	l.log.Infof(context.Background(), "%s", Redact(fmt.Sprintf(msg, args...)))
 }
 func (l *RabbitMQLogger) Debugf(msg string, args ...any) {
-	l.log.Debugf(context.Background(), msg, args...)
+	l.log.Debugf(context.Background(), "%s", Redact(fmt.Sprintf(msg, args...)))
 }
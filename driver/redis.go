@@ -1,6 +1,8 @@
 package driver
 
 import (
+	"context"
+	"crypto/tls"
 	"errors"
 	"github.com/aarioai/airis/aa"
 	"github.com/aarioai/airis/aa/ae"
@@ -9,22 +11,29 @@ import (
 	"github.com/aarioai/airis/pkg/types"
 	"github.com/aarioai/airis/pkg/utils"
 	"github.com/redis/go-redis/v9"
-	"sync"
+	"net"
 	"time"
 )
 
-var (
-	redisClients sync.Map
-)
-
 // NewRedis
 // Note: better use NewRedisPool instead
 func NewRedis(app *aa.App, section string) (*redis.Client, *ae.Error) {
+	defer beginSecretPass()()
 	opts, err := ParseRedisConfig(app, section)
 	if err != nil {
 		return nil, newConfigError(section, err)
 	}
-	return redis.NewClient(opts), nil
+	client := connectRedis(section, opts)
+	loadSlowConfig(app, PoolKindRedis, section)
+	loadRetryPolicy(app, PoolKindRedis, section)
+	loadBreaker(app, PoolKindRedis, section)
+	return client, nil
+}
+
+func connectRedis(section string, opts *redis.Options) *redis.Client {
+	client := redis.NewClient(opts)
+	client.AddHook(redisHook{section: section})
+	return client
 }
 
 // NewRedisPool go-redis 是redis官方推出的，自带连接池、线程安全，不必手动操作
@@ -32,37 +41,37 @@ func NewRedis(app *aa.App, section string) (*redis.Client, *ae.Error) {
 // Warning: Do not unset the returned client as it is managed by the pool
 // Warning: 使用完不要unset client，释放是错误人为操作，可能会导致其他正在使用该client的线程panic，这里不做过度处理。
 func NewRedisPool(app *aa.App, section string) (*redis.Client, *ae.Error) {
-	client, ok := redisClients.Load(section)
-	if ok {
-		if client != nil {
-			return client.(*redis.Client), nil
+	return loadOrNewPool(app, PoolKindRedis, section, func() (*redis.Options, *ae.Error) {
+		opts, err := ParseRedisConfig(app, section)
+		if err != nil {
+			return nil, newConfigError(section, err)
 		}
-		redisClients.Delete(section)
-	}
-	var e *ae.Error
-	client, e = NewRedis(app, section)
-	if e != nil {
-		return nil, e
-	}
-	client, _ = redisClients.LoadOrStore(section, client)
-	return client.(*redis.Client), nil
+		return opts, nil
+	}, func(opts *redis.Options) (*redis.Client, *ae.Error) {
+		// redis.NewClient fills defaults into options, keep the parsed config of PoolConfig untouched
+		o := *opts
+		return connectRedis(section, &o), nil
+	}, func() {
+		loadSlowConfig(app, PoolKindRedis, section)
+		loadRetryPolicy(app, PoolKindRedis, section)
+		loadBreaker(app, PoolKindRedis, section)
+	}, closeRedisClient)
 }
 
 // CloseRedisPool
 // Each process should utilize a single connection, which is managed by the main function.
 // This connection should be closed when the main function terminates.
 func CloseRedisPool() {
-	redisClients.Range(func(k, v interface{}) bool {
-		client := v.(*redis.Client)
-		if client != nil {
-			alog.Stopf("redis client: %s", k)
-			return client.Close() == nil
-		}
-		return true
-	})
+	alog.OnError(ClosePools(context.Background(), PoolKindRedis))
+}
+
+func closeRedisClient(ctx context.Context, section string, client *redis.Client) error {
+	alog.Stopf("redis client: %s", section)
+	return client.Close()
 }
 
 func ParseRedisConfig(app *aa.App, section string) (*redis.Options, error) {
+	defer beginSecretPass()()
 	var connTimeout, readTimeout, writeTimeout time.Duration
 	addr, err := tryGetSectionCfg(app, "redis", section, "addr")
 	if err != nil {
@@ -77,7 +86,7 @@ func ParseRedisConfig(app *aa.App, section string) (*redis.Options, error) {
 	maxRetries, _ := tryGetSectionCfg(app, "redis", section, "max_retries")
 	minRetryBackoff, _ := tryGetSectionCfg(app, "redis", section, "min_retry_backoff")
 	maxRetryBackoff, _ := tryGetSectionCfg(app, "redis", section, "max_retry_backoff")
-	if timeout, err := tryGetSectionCfg(app, "redis", section, "max_retry_backoff"); err == nil {
+	if timeout, err := tryGetSectionCfg(app, "redis", section, "timeout"); err == nil {
 		connTimeout, readTimeout, writeTimeout = ParseTimeouts(timeout)
 	}
 
@@ -93,6 +102,20 @@ func ParseRedisConfig(app *aa.App, section string) (*redis.Options, error) {
 	disableIdentity, _ := tryGetSectionCfg(app, "redis", section, "disable_identity")
 	identitySuffix, _ := tryGetSectionCfg(app, "redis", section, "identity_suffix")
 	unstableResp3, _ := tryGetSectionCfg(app, "redis", section, "unstable_resp3")
+	enableTLS, _ := tryGetSectionCfg(app, "redis", section, "tls") // url 为 rediss:// 时为 true
+	var tlsConfig *tls.Config
+	if types.ToBool(enableTLS) {
+		serverName, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			serverName = addr
+		}
+		tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12, ServerName: serverName}
+	}
+	retries := types.ToInt(maxRetries)
+	if ParseRetryPolicy(app, "redis", section).Enabled() {
+		// the section retry policy retries in redisHook, -1 disables go-redis's own retries to avoid retrying twice
+		retries = -1
+	}
 	newV := atype.New()
 	defer newV.Close()
 
@@ -109,7 +132,7 @@ func ParseRedisConfig(app *aa.App, section string) (*redis.Options, error) {
 		CredentialsProvider:        nil,
 		CredentialsProviderContext: nil,
 		DB:                         types.ToInt(db),
-		MaxRetries:                 types.ToInt(maxRetries),
+		MaxRetries:                 retries,
 		MinRetryBackoff:            types.ParseDuration(minRetryBackoff),
 		MaxRetryBackoff:            types.ParseDuration(maxRetryBackoff),
 		DialTimeout:                connTimeout,
@@ -124,7 +147,7 @@ func ParseRedisConfig(app *aa.App, section string) (*redis.Options, error) {
 		MaxActiveConns:             types.ToInt(maxActiveConns),
 		ConnMaxIdleTime:            types.ParseDuration(connMaxIdleTime),
 		ConnMaxLifetime:            types.ParseDuration(connMaxLifetime),
-		TLSConfig:                  nil,
+		TLSConfig:                  tlsConfig,
 		Limiter:                    nil,
 		// 官方写错，会在 v10 更正过来
 		DisableIndentity: types.ToBool(disableIdentity),
@@ -134,16 +157,76 @@ func ParseRedisConfig(app *aa.App, section string) (*redis.Options, error) {
 	return &opt, nil
 }
 
-// NewRedisError 处理 Redis 错误
-// @TODO
+const (
+	redisTimeoutMsg      = "redis timeout: "
+	redisPoolMsg         = "redis pool exhausted: "
+	redisUnavailableMsg  = "redis unavailable: "
+	redisRedirectMsg     = "redis cluster redirect: "
+	redisNoScriptMsg     = "redis script not loaded: "
+	redisWrongTypeMsg    = "redis wrong type: "
+	redisOOMMsg          = "redis out of memory: "
+	redisAuthMsg         = "redis auth failed: "
+	redisErrorMsg        = "redis: "
+	redisNotFoundMessage = "redis key not found"
+)
+
+// redisErrorPrefixes 服务端错误前缀 -> ae code
+var redisErrorPrefixes = []struct {
+	prefix string
+	code   int
+	msg    string
+}{
+	{"READONLY", ae.ServiceUnavailable, redisUnavailableMsg},
+	{"LOADING", ae.ServiceUnavailable, redisUnavailableMsg},
+	{"MASTERDOWN", ae.ServiceUnavailable, redisUnavailableMsg},
+	{"CLUSTERDOWN", ae.ServiceUnavailable, redisUnavailableMsg},
+	{"TRYAGAIN", ae.ServiceUnavailable, redisUnavailableMsg},
+	{"max number of clients reached", ae.ServiceUnavailable, redisUnavailableMsg},
+	{"MOVED", ae.BadGateway, redisRedirectMsg},
+	{"ASK", ae.BadGateway, redisRedirectMsg},
+	{"NOSCRIPT", ae.PreconditionFailed, redisNoScriptMsg},
+	{"WRONGTYPE", ae.UnprocessableEntity, redisWrongTypeMsg},
+	{"OOM", ae.InsufficientStorage, redisOOMMsg},
+	{"NOAUTH", ae.VariantAlsoNegotiates, redisAuthMsg},
+	{"WRONGPASS", ae.VariantAlsoNegotiates, redisAuthMsg},
+	{"NOPERM", ae.VariantAlsoNegotiates, redisAuthMsg},
+	{"invalid password", ae.VariantAlsoNegotiates, redisAuthMsg},
+}
+
+// classifyRedisError returns the ae code of err:
+//   - timeout: ae.GatewayTimeout, retryable
+//   - pool timeout/exhausted, READONLY, LOADING, cluster down: ae.ServiceUnavailable, retryable
+//   - MOVED/ASK: ae.BadGateway, NOSCRIPT: ae.PreconditionFailed, WRONGTYPE: ae.UnprocessableEntity
+//   - OOM: ae.InsufficientStorage, auth failures: ae.VariantAlsoNegotiates as config errors
+func classifyRedisError(err error) (int, string) {
+	if errors.Is(err, redis.ErrPoolTimeout) || errors.Is(err, redis.ErrPoolExhausted) {
+		return ae.ServiceUnavailable, redisPoolMsg
+	}
+	var ne net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &ne) && ne.Timeout()) {
+		return ae.GatewayTimeout, redisTimeoutMsg
+	}
+	for _, p := range redisErrorPrefixes {
+		if redis.HasErrorPrefix(err, p.prefix) {
+			return p.code, p.msg
+		}
+	}
+	return ae.InternalServerError, redisErrorMsg
+}
+
+// NewRedisError 处理 Redis 错误，IsRetryable 可判断是否可重试
 func NewRedisError(err error, details ...any) *ae.Error {
 	if err == nil {
 		return nil
 	}
 	if errors.Is(err, redis.Nil) {
-		return ae.New(ae.NotFound, "redis key not found").WithDetail(details...)
+		return ae.New(ae.NotFound, redisNotFoundMessage).WithDetail(details...)
+	}
+	if errors.Is(err, ErrCircuitOpen) {
+		return ErrorCircuitOpen
 	}
-	msg := err.Error()
+	msg := Redact(err.Error())
 	caller := utils.Caller(1)
-	return ae.New(ae.InternalServerError, caller+" redis: "+msg).WithDetail(details...)
+	code, prefix := classifyRedisError(err)
+	return ae.New(code, caller+" "+prefix+msg).WithDetail(redactDetails(details)...)
 }
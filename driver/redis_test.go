@@ -1,10 +1,13 @@
 package driver_test
 
 import (
+	"context"
+	"fmt"
 	"github.com/aarioai/airis-driver/driver"
 	"github.com/aarioai/airis/aa"
 	"github.com/aarioai/airis/aa/aconfig"
 	"github.com/aarioai/airis/aa/acontext"
+	"github.com/aarioai/airis/aa/ae"
 	"github.com/redis/go-redis/v9"
 	"testing"
 )
@@ -31,6 +34,15 @@ func TestRedis(t *testing.T) {
 	testRedisConfig(t, app, "redis_test", testRedisOpt)
 	testRedisConfig(t, app, "test", testRedisOpt)
 	testRedisConfig(t, app, "redis_test2", test2RedisOpt)
+
+	// the section retry policy takes over, go-redis must not retry on its own
+	opts, e := driver.ParseRedisConfig(app, "redis_retry")
+	if e != nil {
+		t.Fatal(e.Error())
+	}
+	if opts.MaxRetries != -1 {
+		t.Errorf("redis max_retries %d, want -1 under a section retry policy", opts.MaxRetries)
+	}
 }
 func testRedisConfig(t *testing.T, app *aa.App, section string, want redis.Options) {
 	test, err := driver.ParseRedisConfig(app, section)
@@ -48,3 +60,42 @@ func testRedisConfig(t *testing.T, app *aa.App, section string, want redis.Optio
 	}
 
 }
+
+// redisServerError is a redis error reply, e.g. "READONLY You can't write against a read only replica."
+type redisServerError string
+
+func (e redisServerError) Error() string { return string(e) }
+func (e redisServerError) RedisError()   {}
+
+func TestNewRedisError(t *testing.T) {
+	if e := driver.NewRedisError(redis.Nil); e.Code != ae.NotFound || driver.IsRetryable(e) {
+		t.Errorf("redis nil: %+v", e)
+	}
+	cases := []struct {
+		err       error
+		code      int
+		retryable bool
+	}{
+		{redis.ErrPoolTimeout, ae.ServiceUnavailable, true},
+		{fmt.Errorf("get: %w", context.DeadlineExceeded), ae.GatewayTimeout, true},
+		{redisServerError("READONLY You can't write against a read only replica."), ae.ServiceUnavailable, true},
+		{redisServerError("LOADING Redis is loading the dataset in memory"), ae.ServiceUnavailable, true},
+		{redisServerError("MOVED 3999 127.0.0.1:6381"), ae.BadGateway, false},
+		{redisServerError("ASK 3999 127.0.0.1:6381"), ae.BadGateway, false},
+		{redisServerError("NOSCRIPT No matching script."), ae.PreconditionFailed, false},
+		{redisServerError("WRONGTYPE Operation against a key holding the wrong kind of value"), ae.UnprocessableEntity, false},
+		{redisServerError("OOM command not allowed when used memory > 'maxmemory'."), ae.InsufficientStorage, false},
+		{redisServerError("WRONGPASS invalid username-password pair or user is disabled."), ae.VariantAlsoNegotiates, false},
+		{redisServerError("NOAUTH Authentication required."), ae.VariantAlsoNegotiates, false},
+		{redisServerError("ERR syntax error"), ae.InternalServerError, false},
+	}
+	for _, c := range cases {
+		e := driver.NewRedisError(c.err, "key")
+		if e.Code != c.code {
+			t.Errorf("%s: code %d, want %d", c.err, e.Code, c.code)
+		}
+		if driver.IsRetryable(e) != c.retryable || driver.IsRetryable(c.err) != c.retryable {
+			t.Errorf("%s: retryable should be %v", c.err, c.retryable)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package driver
+
+import (
+	"context"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aarioai/airis/aa"
+	"github.com/aarioai/airis/aa/ae"
+	"github.com/aarioai/airis/pkg/types"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	DefaultRetryBackoff    = 50 * time.Millisecond
+	DefaultRetryMaxBackoff = 2 * time.Second
+	DefaultRetryJitter     = 0.5
+
+	httpRetryDrainLimit = 4096
+)
+
+// RetryPolicy retries an operation failed with a retryable error, with exponential backoff and jitter.
+// The zero value never retries. Configure it per section with retry_attempts, retry_backoff and retry_max_backoff.
+type RetryPolicy struct {
+	MaxAttempts int           // including the first attempt, <= 1 disables retrying
+	Backoff     time.Duration // delay before the first retry, doubled for each next retry
+	MaxBackoff  time.Duration
+	Jitter      float64            // 0-1, the delay is randomized within [delay*(1-Jitter), delay]
+	Retryable   func(err any) bool // error or *ae.Error, nil uses IsRetryable
+}
+
+func (p RetryPolicy) Enabled() bool {
+	return p.MaxAttempts > 1
+}
+
+// Delay returns the backoff before the n-th retry, n starts from 1
+func (p RetryPolicy) Delay(n int) time.Duration {
+	d := p.Backoff
+	for i := 1; i < n && (p.MaxBackoff <= 0 || d < p.MaxBackoff); i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if j := min(p.Jitter, 1); j > 0 && d > 0 {
+		d -= time.Duration(rand.Float64() * j * float64(d))
+	}
+	return d
+}
+
+func (p RetryPolicy) isRetryable(err any) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return IsRetryable(err)
+}
+
+// shouldRetry reports whether another attempt is allowed after the attempt-th one failed
+func (p RetryPolicy) shouldRetry(attempt int, retryable bool) bool {
+	return retryable && attempt < p.MaxAttempts
+}
+
+// wait sleeps before the next attempt, it returns false if ctx is done
+func (p RetryPolicy) wait(ctx context.Context, attempt int) bool {
+	t := time.NewTimer(p.Delay(attempt))
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+// Do calls fn until it succeeds, fails with a non-retryable error, the attempts are used up or ctx is done.
+// It returns the last error.
+func (p RetryPolicy) Do(ctx context.Context, fn func(ctx context.Context) *ae.Error) *ae.Error {
+	for attempt := 1; ; attempt++ {
+		e := fn(ctx)
+		if e == nil || !p.shouldRetry(attempt, p.isRetryable(e)) || !p.wait(ctx, attempt) {
+			return e
+		}
+	}
+}
+
+// doErr is Do for functions of the client libraries
+func (p RetryPolicy) doErr(ctx context.Context, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || !p.shouldRetry(attempt, p.isRetryable(err)) || !p.wait(ctx, attempt) {
+			return err
+		}
+	}
+}
+
+// Retry is RetryPolicy.Do for functions with a result
+func Retry[T any](ctx context.Context, p RetryPolicy, fn func(ctx context.Context) (T, *ae.Error)) (T, *ae.Error) {
+	var result T
+	e := p.Do(ctx, func(ctx context.Context) *ae.Error {
+		var e *ae.Error
+		result, e = fn(ctx)
+		return e
+	})
+	return result, e
+}
+
+type idempotentKey struct{}
+
+// Idempotent marks the operations with ctx safe to retry. Reads are always retried according to the retry policy of
+// the section, writes (sql exec, transaction blocks, mongodb writes, redis writes and non-idempotent http methods) are
+// retried only with an idempotent ctx.
+func Idempotent(ctx context.Context) context.Context {
+	return context.WithValue(ctx, idempotentKey{}, true)
+}
+
+func IsIdempotent(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	v, _ := ctx.Value(idempotentKey{}).(bool)
+	return v
+}
+
+var retryPolicies sync.Map // poolKey -> RetryPolicy
+
+// ParseRetryPolicy reads retry_attempts, retry_backoff and retry_max_backoff of the section, e.g. [mysql_user]
+func ParseRetryPolicy(app *aa.App, base, section string) RetryPolicy {
+	attempts, _ := tryGetSectionCfg(app, base, section, "retry_attempts")
+	backoff, _ := tryGetSectionCfg(app, base, section, "retry_backoff")
+	maxBackoff, _ := tryGetSectionCfg(app, base, section, "retry_max_backoff")
+	n, _ := types.ParseInt(attempts)
+	return RetryPolicy{
+		MaxAttempts: n,
+		Backoff:     types.ParseDuration(backoff, DefaultRetryBackoff),
+		MaxBackoff:  types.ParseDuration(maxBackoff, DefaultRetryMaxBackoff),
+		Jitter:      DefaultRetryJitter,
+	}
+}
+
+// loadRetryPolicy is called after the client of the section was (re)built or reloaded, like loadSlowConfig
+func loadRetryPolicy(app *aa.App, kind PoolKind, section string) {
+	key := poolKey{kind: kind, section: section}
+	if p := ParseRetryPolicy(app, string(kind), section); p.Enabled() {
+		retryPolicies.Store(key, p)
+		return
+	}
+	retryPolicies.Delete(key)
+}
+
+// SectionRetryPolicy returns the retry policy of the mysql, redis or mongodb section, the zero value if not configured
+func SectionRetryPolicy(kind PoolKind, section string) RetryPolicy {
+	if p, ok := retryPolicies.Load(poolKey{kind: kind, section: section}); ok {
+		return p.(RetryPolicy)
+	}
+	return RetryPolicy{}
+}
+
+// redisReadOnlyCommands are safe to retry without an idempotent ctx
+var redisReadOnlyCommands = map[string]bool{
+	"get": true, "mget": true, "strlen": true, "getrange": true, "exists": true, "ttl": true, "pttl": true, "type": true,
+	"hget": true, "hmget": true, "hgetall": true, "hexists": true, "hlen": true, "hkeys": true, "hvals": true,
+	"smembers": true, "sismember": true, "smismember": true, "scard": true, "sunion": true, "sinter": true, "sdiff": true,
+	"zrange": true, "zrangebyscore": true, "zrevrange": true, "zrevrangebyscore": true, "zscore": true, "zmscore": true,
+	"zcard": true, "zcount": true, "zrank": true, "zrevrank": true, "lrange": true, "llen": true, "lindex": true,
+	"scan": true, "hscan": true, "sscan": true, "zscan": true, "ping": true,
+}
+
+func redisRetryable(ctx context.Context, cmds ...redis.Cmder) bool {
+	if IsIdempotent(ctx) {
+		return true
+	}
+	for _, cmd := range cmds {
+		if !redisReadOnlyCommands[cmd.Name()] {
+			return false
+		}
+	}
+	return true
+}
+
+// httpIdempotentMethods https://www.rfc-editor.org/rfc/rfc9110#section-9.2.2
+var httpIdempotentMethods = map[string]bool{
+	http.MethodGet: true, http.MethodHead: true, http.MethodOptions: true, http.MethodTrace: true,
+	http.MethodPut: true, http.MethodDelete: true,
+}
+
+// httpRetryTransport retries idempotent requests, i.e. idempotent methods, requests with an Idempotency-Key header or
+// an idempotent ctx. A request with a body is retried only if the body can be rewound (http.Request.GetBody).
+type httpRetryTransport struct {
+	policy RetryPolicy
+	base   http.RoundTripper
+}
+
+func httpRetryableRequest(r *http.Request) bool {
+	if r.Body != nil && r.Body != http.NoBody && r.GetBody == nil {
+		return false
+	}
+	return httpIdempotentMethods[r.Method] || r.Header.Get("Idempotency-Key") != "" ||
+		r.Header.Get("X-Idempotency-Key") != "" || IsIdempotent(r.Context())
+}
+
+// httpRetryableResponse transport errors, 429, 502, 503 and 504 are retryable
+func httpRetryableResponse(r *http.Request, resp *http.Response, err error) bool {
+	if err != nil {
+		return r.Context().Err() == nil
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func (t httpRetryTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if !httpRetryableRequest(r) {
+		return t.base.RoundTrip(r)
+	}
+	ctx := r.Context()
+	for attempt := 1; ; attempt++ {
+		resp, err := t.base.RoundTrip(r)
+		if !t.policy.shouldRetry(attempt, httpRetryableResponse(r, resp, err)) {
+			return resp, err
+		}
+		if resp != nil {
+			_, _ = io.CopyN(io.Discard, resp.Body, httpRetryDrainLimit)
+			_ = resp.Body.Close()
+		}
+		if !t.policy.wait(ctx, attempt) {
+			return nil, ctx.Err()
+		}
+		if r.GetBody != nil {
+			body, err := r.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			r = r.Clone(ctx)
+			r.Body = body
+		}
+	}
+}
+
+// retryConflicts checks the retry keys of any section
+func retryConflicts(v map[string]string) []string {
+	backoff, maxBackoff := cfgDurationOf(v["retry_backoff"]), cfgDurationOf(v["retry_max_backoff"])
+	if maxBackoff > 0 && backoff > maxBackoff {
+		return []string{"retry_backoff " + backoff.String() + " > retry_max_backoff " + maxBackoff.String()}
+	}
+	return nil
+}
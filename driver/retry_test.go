@@ -0,0 +1,112 @@
+package driver_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aarioai/airis-driver/driver"
+	"github.com/aarioai/airis/aa"
+	"github.com/aarioai/airis/aa/aconfig"
+	"github.com/aarioai/airis/aa/acontext"
+	"github.com/aarioai/airis/aa/ae"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	p := driver.RetryPolicy{MaxAttempts: 3, Backoff: 10 * time.Millisecond, MaxBackoff: 25 * time.Millisecond, Jitter: 0.5}
+	for n, want := range map[int]time.Duration{1: 10 * time.Millisecond, 2: 20 * time.Millisecond, 5: 25 * time.Millisecond} {
+		if d := p.Delay(n); d > want || d < want/2 {
+			t.Errorf("delay of retry %d: %s, want [%s, %s]", n, d, want/2, want)
+		}
+	}
+
+	p.Backoff, p.MaxBackoff = time.Millisecond, time.Millisecond
+	var calls int
+	e := p.Do(context.Background(), func(ctx context.Context) *ae.Error {
+		calls++
+		return ae.New(ae.Locked, "deadlock")
+	})
+	if e == nil || calls != 3 {
+		t.Errorf("retryable error: %d calls, want 3", calls)
+	}
+
+	calls = 0
+	n, e := driver.Retry(context.Background(), p, func(ctx context.Context) (int, *ae.Error) {
+		calls++
+		if calls == 1 {
+			return 0, ae.New(ae.ServiceUnavailable, "readonly")
+		}
+		return calls, nil
+	})
+	if e != nil || n != 2 {
+		t.Errorf("retry: %d %v", n, e)
+	}
+
+	calls = 0
+	p.Do(context.Background(), func(ctx context.Context) *ae.Error {
+		calls++
+		return ae.New(ae.Conflict, "duplicate")
+	})
+	if calls != 1 {
+		t.Errorf("non-retryable error retried %d times", calls-1)
+	}
+
+	if driver.IsIdempotent(context.Background()) || !driver.IsIdempotent(driver.Idempotent(context.Background())) {
+		t.Error("idempotent ctx")
+	}
+}
+
+func TestHttpRetry(t *testing.T) {
+	c, err := aconfig.New("./test_config.ini", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := acontext.WithCancel(acontext.Background())
+	app := aa.New(ctx, cancel, c)
+
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1)%3 != 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	client, e := driver.NewHttpClient(app, "httpc_retry") // retry_attempts = 3
+	if e != nil {
+		t.Fatal(e.Msg)
+	}
+	resp, err := client.Post(srv.URL, "text/plain", strings.NewReader("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || hits.Load() != 1 {
+		t.Errorf("POST should not be retried, status %d, hits %d", resp.StatusCode, hits.Load())
+	}
+
+	hits.Store(0)
+	req, _ := http.NewRequestWithContext(driver.Idempotent(context.Background()), http.MethodPost, srv.URL, strings.NewReader("hello"))
+	if resp, err = client.Do(req); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || hits.Load() != 3 {
+		t.Errorf("idempotent POST: status %d, hits %d", resp.StatusCode, hits.Load())
+	}
+
+	hits.Store(0)
+	if resp, err = client.Get(srv.URL); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || hits.Load() != 3 {
+		t.Errorf("GET: status %d, hits %d", resp.StatusCode, hits.Load())
+	}
+}
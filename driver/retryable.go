@@ -0,0 +1,41 @@
+package driver
+
+import (
+	"errors"
+
+	"github.com/aarioai/airis/aa/ae"
+	"github.com/go-sql-driver/mysql"
+	"github.com/redis/go-redis/v9"
+)
+
+// retryable ae codes set by New*Error
+func isRetryableCode(code int) bool {
+	return code == ae.Locked || code == ae.ServiceUnavailable || code == ae.GatewayTimeout
+}
+
+// IsRetryable reports whether the failed operation is transient and safe to retry, e.g. MySQL/PostgreSQL deadlock, lock
+// wait timeout, SQLite busy, redis timeout, pool exhaustion, READONLY or LOADING, mongodb write conflict or transient transaction errors.
+// err can be an error or the *ae.Error returned by New*Error.
+func IsRetryable(err any) bool {
+	switch e := err.(type) {
+	case *ae.Error:
+		return e != nil && e != ErrorCircuitOpen && isRetryableCode(e.Code)
+	case error:
+		var me *mysql.MySQLError
+		if errors.As(e, &me) {
+			return me.Number == mysqlErrDeadlock || me.Number == mysqlErrLockWaitTimeout
+		}
+		if isRetryablePostgresError(e) || isRetryableSqliteError(e) {
+			return true
+		}
+		if code, _, ok := classifyMongodbError(e); ok {
+			return isRetryableCode(code)
+		}
+		if errors.Is(e, redis.Nil) || errors.Is(e, ErrCircuitOpen) {
+			return false
+		}
+		code, _ := classifyRedisError(e)
+		return isRetryableCode(code)
+	}
+	return false
+}
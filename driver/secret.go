@@ -0,0 +1,174 @@
+package driver
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SecretResolver resolves the reference part of a config value `<scheme>:<reference>`
+// E.g. a vault resolver registered as "vault" resolves `vault:secret/data/mysql#password`
+type SecretResolver interface {
+	Resolve(ref string) (string, error)
+}
+
+type SecretResolverFunc func(ref string) (string, error)
+
+func (f SecretResolverFunc) Resolve(ref string) (string, error) {
+	return f(ref)
+}
+
+// DefaultSecretCmdTimeout is the timeout of a cmd: secret command
+const DefaultSecretCmdTimeout = 10 * time.Second
+
+var (
+	secretResolvers = map[string]SecretResolver{
+		"env":  SecretResolverFunc(resolveEnvSecret),
+		"file": SecretResolverFunc(resolveFileSecret),
+		"cmd":  SecretResolverFunc(resolveCmdSecret),
+	}
+	secretResolversMtx sync.RWMutex
+
+	secretRefPattern   = regexp.MustCompile(`^([a-z][a-z0-9_-]*):(.+)$`)
+	secretInterpolates = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)}`)
+)
+
+// RegisterSecretResolver registers (or replaces) the resolver of a scheme
+func RegisterSecretResolver(scheme string, resolver SecretResolver) {
+	secretResolversMtx.Lock()
+	defer secretResolversMtx.Unlock()
+	secretResolvers[scheme] = resolver
+}
+
+// ResolveSecret resolves a config value
+//
+//	env:MYSQL_PASSWORD          value of env MYSQL_PASSWORD
+//	file:/run/secrets/redis     content of the file, trailing newline is trimmed. The file is read on every call, so it
+//	                            is re-read on pool reload
+//	cmd:pass show db/mysql      stdout of the command (not run in a shell), trailing newline is trimmed. It's run with
+//	                            DefaultSecretCmdTimeout, once per config parse or pool reload
+//	<scheme>:<reference>        registered by RegisterSecretResolver
+//	tcp(${DB_HOST}:3306)        ${VAR} is interpolated with env
+//
+// Values whose scheme is not registered (e.g. https://luexu.com, 127.0.0.1:6379) are returned as is.
+func ResolveSecret(v string) (string, error) {
+	if matches := secretRefPattern.FindStringSubmatch(v); len(matches) == 3 {
+		secretResolversMtx.RLock()
+		resolver, ok := secretResolvers[matches[1]]
+		secretResolversMtx.RUnlock()
+		if ok {
+			s, err := resolver.Resolve(matches[2])
+			if err != nil {
+				return "", fmt.Errorf("resolve secret %s: %w", matches[1], err)
+			}
+			return s, nil
+		}
+	}
+	if !strings.Contains(v, "${") {
+		return v, nil
+	}
+	var errs []error
+	v = secretInterpolates.ReplaceAllStringFunc(v, func(s string) string {
+		name := s[2 : len(s)-1]
+		env, ok := os.LookupEnv(name)
+		if !ok {
+			errs = append(errs, fmt.Errorf("env %s is not set", name))
+		}
+		return env
+	})
+	return v, errors.Join(errs...)
+}
+
+type secretResult struct {
+	v   string
+	err error
+}
+
+// secretPass memoizes the resolved config values while sections are parsed, so that a cmd: secret, or the url of a
+// section that every key is looked up from, is resolved once per parse, reload or validation instead of once per key
+var secretPass struct {
+	mtx    sync.Mutex
+	refs   int
+	values map[string]secretResult
+}
+
+// beginSecretPass starts (or joins) a secret pass, the memoized values are dropped when the last pass ends
+func beginSecretPass() (end func()) {
+	secretPass.mtx.Lock()
+	if secretPass.refs == 0 {
+		secretPass.values = make(map[string]secretResult)
+	}
+	secretPass.refs++
+	secretPass.mtx.Unlock()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			secretPass.mtx.Lock()
+			if secretPass.refs--; secretPass.refs == 0 {
+				secretPass.values = nil
+			}
+			secretPass.mtx.Unlock()
+		})
+	}
+}
+
+// resolveConfigValue is ResolveSecret, memoized in a secret pass
+func resolveConfigValue(v string) (string, error) {
+	secretPass.mtx.Lock()
+	r, ok := secretPass.values[v]
+	active := secretPass.values != nil
+	secretPass.mtx.Unlock()
+	if ok {
+		return r.v, r.err
+	}
+	s, err := ResolveSecret(v)
+	if active {
+		secretPass.mtx.Lock()
+		if secretPass.values != nil {
+			secretPass.values[v] = secretResult{s, err}
+		}
+		secretPass.mtx.Unlock()
+	}
+	return s, err
+}
+
+func resolveEnvSecret(name string) (string, error) {
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("env %s is not set", name)
+	}
+	return v, nil
+}
+
+func resolveFileSecret(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
+
+func resolveCmdSecret(command string) (string, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return "", errors.New("empty command")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultSecretCmdTimeout)
+	defer cancel()
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		// 只输出命令名，参数可能带有密钥路径等
+		return "", fmt.Errorf("%s: %w %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimRight(string(out), "\r\n"), nil
+}
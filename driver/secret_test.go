@@ -0,0 +1,106 @@
+package driver_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aarioai/airis-driver/driver"
+	"github.com/aarioai/airis/aa"
+	"github.com/aarioai/airis/aa/aconfig"
+	"github.com/aarioai/airis/aa/acontext"
+)
+
+func TestResolveSecret(t *testing.T) {
+	t.Setenv("AIRIS_DRIVER_TEST_SECRET", "Luexu.com")
+	file := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(file, []byte("Aario\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	driver.RegisterSecretResolver("vault", driver.SecretResolverFunc(func(ref string) (string, error) {
+		return "vault/" + ref, nil
+	}))
+
+	wants := map[string]string{
+		"env:AIRIS_DRIVER_TEST_SECRET":          "Luexu.com",
+		"file:" + file:                          "Aario",
+		"vault:mysql#password":                  "vault/mysql#password",
+		"cmd:echo Luexu.com":                    "Luexu.com",
+		"${AIRIS_DRIVER_TEST_SECRET}:6379":      "Luexu.com:6379",
+		"https://luexu.com":                     "https://luexu.com",
+		"luexu.com:6379":                        "luexu.com:6379",
+		"tcp(${AIRIS_DRIVER_TEST_SECRET}:3306)": "tcp(Luexu.com:3306)",
+	}
+	for v, want := range wants {
+		got, err := driver.ResolveSecret(v)
+		if err != nil {
+			t.Errorf("resolve secret %s: %s", v, err)
+		} else if got != want {
+			t.Errorf("resolve secret %s = %s, want %s", v, got, want)
+		}
+	}
+	if _, err := driver.ResolveSecret("env:AIRIS_DRIVER_TEST_NOT_EXISTS"); err == nil {
+		t.Error("resolve missing env secret should fail")
+	}
+	if _, err := driver.ResolveSecret("cmd:false"); err == nil {
+		t.Error("resolve failed cmd secret should fail")
+	}
+}
+
+func TestSecretConfig(t *testing.T) {
+	t.Setenv("AIRIS_DRIVER_TEST_REDIS_PASSWORD", "Luexu.com")
+	t.Setenv("AIRIS_DRIVER_TEST_REDIS_HOST", "luexu.com")
+	c, err := aconfig.New("./test_config.ini", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := acontext.WithCancel(acontext.Background())
+	app := aa.New(ctx, cancel, c)
+
+	opts, err := driver.ParseRedisConfig(app, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.Password != "Luexu.com" {
+		t.Errorf("redis secret password %s not match Luexu.com", opts.Password)
+	}
+	if opts.Addr != "luexu.com:6379" {
+		t.Errorf("redis secret addr %s not match luexu.com:6379", opts.Addr)
+	}
+}
+
+func TestSecretURLConfig(t *testing.T) {
+	dir := t.TempDir()
+	counter := filepath.Join(dir, "count")
+	script := filepath.Join(dir, "url.sh")
+	// the password of the resolved url looks like a secret reference, it must not be resolved again
+	sh := "#!/bin/sh\necho x >> " + counter + "\necho 'redis://:env:${AIRIS}@luexu.com:6379/2'\n"
+	if err := os.WriteFile(script, []byte(sh), 0700); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "config.ini")
+	if err := os.WriteFile(file, []byte("[redis_cmd]\nurl = cmd:"+script+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := aconfig.New(file, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := acontext.WithCancel(acontext.Background())
+	app := aa.New(ctx, cancel, c)
+
+	opts, err := driver.ParseRedisConfig(app, "redis_cmd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.Password != "env:${AIRIS}" || opts.Addr != "luexu.com:6379" || opts.DB != 2 {
+		t.Errorf("redis url config: %s %s %d", opts.Password, opts.Addr, opts.DB)
+	}
+	b, err := os.ReadFile(counter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(b) / 2; n != 1 {
+		t.Errorf("cmd secret url run %d times, want once", n)
+	}
+}
@@ -23,7 +23,7 @@ func CheckMongodbHealth(section string) func(app *aa.App, errChan chan<- error)
 		defer client.Disconnect(ctx)
 
 		if err := client.Ping(ctx, nil); err != nil {
-			errChan <- fmt.Errorf("mongodb (%s) ping error: %s", section, err.Error())
+			errChan <- fmt.Errorf("mongodb (%s) ping error: %s", section, Redact(err.Error()))
 			return
 		}
 		alog.Console("mongodb [" + section + "] is healthy")
@@ -42,7 +42,7 @@ func CheckMySQLHealth(section string) func(app *aa.App, errChan chan<- error) {
 		defer db.Close()
 
 		if err := db.Ping(); err != nil {
-			errChan <- fmt.Errorf("mysql (%s) ping error: %s", section, err.Error())
+			errChan <- fmt.Errorf("mysql (%s) ping error: %s", section, Redact(err.Error()))
 			return
 		}
 		alog.Console("mysql [" + section + "] is healthy")
@@ -65,7 +65,7 @@ func CheckRabbitmqHealth(section string, tlsConfig *tls.Config, sasl []amqp091.A
 			rabbitmq.WithConsumerOptionsLogging,
 		)
 		if err != nil {
-			errChan <- fmt.Errorf("rabbitmq (%s) ping error: %s", section, err.Error())
+			errChan <- fmt.Errorf("rabbitmq (%s) ping error: %s", section, Redact(err.Error()))
 			return
 		}
 		defer consumer.Close()
@@ -85,7 +85,7 @@ func CheckRedisHealth(section string) func(app *aa.App, errChan chan<- error) {
 		defer client.Close()
 		_, err := client.Ping(context.Background()).Result()
 		if err != nil {
-			errChan <- fmt.Errorf("redis (%s) ping error: %s", section, err.Error())
+			errChan <- fmt.Errorf("redis (%s) ping error: %s", section, Redact(err.Error()))
 			return
 		}
 		alog.Console("redis [" + section + "] is healthy")
@@ -0,0 +1,142 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ShutdownStage CloseAllPools 按阶段依次关闭，同一阶段内并发关闭
+type ShutdownStage int
+
+const (
+	ShutdownProducers ShutdownStage = iota // rabbitmq publishers/consumers, influxdb async writers are flushed
+	ShutdownBrokers                        // rabbitmq connections, influxdb clients
+	ShutdownStores                         // mysql, redis, mongodb
+)
+
+type shutdownTask struct {
+	name     string
+	close    func(ctx context.Context) error
+	prefixed bool // the error of close already starts with the name
+}
+
+var (
+	shutdownHooks   = make(map[ShutdownStage][]shutdownTask)
+	shutdownHooksMu sync.Mutex
+)
+
+// OnShutdown registers a closer that CloseAllPools runs in the stage, e.g. closing a rabbitmq publisher:
+//
+//	driver.OnShutdown(driver.ShutdownProducers, "rabbitmq publisher order", func(ctx context.Context) error {
+//		publisher.Close()
+//		return nil
+//	})
+func OnShutdown(stage ShutdownStage, name string, closer func(ctx context.Context) error) {
+	shutdownHooksMu.Lock()
+	defer shutdownHooksMu.Unlock()
+	shutdownHooks[stage] = append(shutdownHooks[stage], shutdownTask{name: name, close: closer})
+}
+
+func takeShutdownHooks(stage ShutdownStage) []shutdownTask {
+	shutdownHooksMu.Lock()
+	defer shutdownHooksMu.Unlock()
+	tasks := shutdownHooks[stage]
+	delete(shutdownHooks, stage)
+	return tasks
+}
+
+// CloseAllPools closes everything in order: producers are closed and influxdb write buffers are flushed first, then
+// rabbitmq/influxdb clients, and stores at last. It honours the ctx deadline, won't stop on failure, and returns
+// the joined errors of every failed section.
+func CloseAllPools(ctx context.Context) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var errs []error
+	// 1. producers
+	tasks := takeShutdownHooks(ShutdownProducers)
+	for _, section := range PoolSections(PoolKindInfluxdb) {
+		tasks = append(tasks, shutdownTask{
+			name: fmt.Sprintf("flush %s [%s]", PoolKindInfluxdb, section),
+			close: func(ctx context.Context) error {
+				if d, ok := PoolClient[InfluxdbClientData](PoolKindInfluxdb, section); ok {
+					d.DefaultWrite.Flush()
+				}
+				return nil
+			},
+		})
+	}
+	errs = append(errs, runShutdownTasks(ctx, tasks)...)
+	// 2. brokers
+	tasks = append(takeShutdownHooks(ShutdownBrokers), poolShutdownTasks(PoolKindRabbitmq, PoolKindInfluxdb)...)
+	errs = append(errs, runShutdownTasks(ctx, tasks)...)
+	// 3. stores
+	tasks = append(takeShutdownHooks(ShutdownStores), poolShutdownTasks(PoolKindMysql, PoolKindPostgres, PoolKindSqlite, PoolKindRedis, PoolKindMongodb)...)
+	errs = append(errs, runShutdownTasks(ctx, tasks)...)
+	return errors.Join(errs...)
+}
+
+// poolShutdownTasks closes every section, and the clients still draining after reload
+func poolShutdownTasks(kinds ...PoolKind) []shutdownTask {
+	var tasks []shutdownTask
+	for _, kind := range kinds {
+		for _, section := range PoolSections(kind) {
+			tasks = append(tasks, shutdownTask{
+				name: fmt.Sprintf("%s [%s]", kind, section),
+				close: func(ctx context.Context) error {
+					return ClosePool(ctx, kind, section)
+				},
+				prefixed: true,
+			})
+		}
+		for _, entry := range pools.takeDraining(kind) {
+			tasks = append(tasks, shutdownTask{
+				name:     fmt.Sprintf("%s [%s] (draining)", kind, entry.key.section),
+				close:    entry.close,
+				prefixed: true,
+			})
+		}
+	}
+	return tasks
+}
+
+// runShutdownTasks runs the tasks concurrently, and stops waiting for the ones not finished before ctx is done
+func runShutdownTasks(ctx context.Context, tasks []shutdownTask) []error {
+	errs := make([]error, len(tasks))
+	var wg sync.WaitGroup
+	for i, task := range tasks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			done := make(chan error, 1)
+			go func() {
+				done <- task.close(ctx)
+			}()
+			var err error
+			select {
+			case err = <-done:
+			case <-ctx.Done():
+				select {
+				case err = <-done:
+				default:
+					errs[i] = fmt.Errorf("%s: %w", task.name, ctx.Err())
+					return
+				}
+			}
+			if err != nil && !task.prefixed {
+				err = fmt.Errorf("%s: %w", task.name, err)
+			}
+			errs[i] = RedactError(err)
+		}()
+	}
+	wg.Wait()
+	var ret []error
+	for _, err := range errs {
+		if err != nil {
+			ret = append(ret, err)
+		}
+	}
+	return ret
+}
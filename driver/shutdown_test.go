@@ -0,0 +1,62 @@
+package driver_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aarioai/airis-driver/driver"
+	"github.com/aarioai/airis/aa"
+	"github.com/aarioai/airis/aa/aconfig"
+	"github.com/aarioai/airis/aa/acontext"
+)
+
+func TestCloseAllPools(t *testing.T) {
+	c, err := aconfig.New("./test_config.ini", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := acontext.WithCancel(acontext.Background())
+	app := aa.New(ctx, cancel, c)
+	if _, e := driver.NewRedisPool(app, "redis_test"); e != nil {
+		t.Fatal(e.Msg)
+	}
+
+	var mtx sync.Mutex
+	var order []string
+	record := func(name string) func(context.Context) error {
+		return func(context.Context) error {
+			mtx.Lock()
+			defer mtx.Unlock()
+			order = append(order, name)
+			return nil
+		}
+	}
+	driver.OnShutdown(driver.ShutdownStores, "store", record("store"))
+	driver.OnShutdown(driver.ShutdownBrokers, "broker", record("broker"))
+	driver.OnShutdown(driver.ShutdownProducers, "producer", record("producer"))
+	driver.OnShutdown(driver.ShutdownProducers, "failed producer", func(context.Context) error {
+		return errors.New("publisher closed")
+	})
+	driver.OnShutdown(driver.ShutdownStores, "stuck store", func(context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+
+	ctx2, cancel2 := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel2()
+	err = driver.CloseAllPools(ctx2)
+	if !slices.Equal(order, []string{"producer", "broker", "store"}) {
+		t.Errorf("shutdown order %v", order)
+	}
+	if err == nil || !strings.Contains(err.Error(), "failed producer: publisher closed") || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("shutdown error: %v", err)
+	}
+	if len(driver.PoolSections(driver.PoolKindRedis)) != 0 {
+		t.Error("redis pool not closed")
+	}
+}
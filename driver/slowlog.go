@@ -0,0 +1,237 @@
+package driver
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aarioai/airis/aa"
+	"github.com/aarioai/airis/aa/alog"
+	"github.com/aarioai/airis/pkg/types"
+)
+
+const (
+	// DefaultSlowSampleInterval 同一语句在该间隔内只记录一次慢日志，其余计入 Skipped
+	DefaultSlowSampleInterval = time.Minute
+
+	slowSamplerCap  = 4096
+	slowArgMaxLen   = 128
+	slowArgsMaxSize = 32
+)
+
+// SlowOperation is an operation slower than the slow_threshold of the section
+type SlowOperation struct {
+	Kind      PoolKind      `json:"kind"`
+	Section   string        `json:"section"`
+	Statement string        `json:"statement"`
+	Args      []string      `json:"args,omitempty"` // normalized and redacted
+	Elapsed   time.Duration `json:"elapsed"`
+	Threshold time.Duration `json:"threshold"`
+	Caller    string        `json:"caller"`
+	Skipped   int           `json:"skipped"` // slow executions of the same statement not logged since the last entry
+}
+
+func (o SlowOperation) String() string {
+	var s strings.Builder
+	fmt.Fprintf(&s, "slow %s [%s] %s (threshold %s) at %s: %s", o.Kind, o.Section, o.Elapsed, o.Threshold, o.Caller, o.Statement)
+	if len(o.Args) > 0 {
+		s.WriteString("; args: [" + strings.Join(o.Args, ", ") + "]")
+	}
+	if o.Skipped > 0 {
+		fmt.Fprintf(&s, " (%d similar skipped)", o.Skipped)
+	}
+	return s.String()
+}
+
+type slowConfig struct {
+	threshold time.Duration
+	interval  time.Duration
+}
+
+type slowLoggerHolder struct {
+	log func(SlowOperation)
+}
+
+var (
+	slowConfigs sync.Map // poolKey -> slowConfig
+	slowLogger  atomic.Pointer[slowLoggerHolder]
+	slowSamples = &slowSampler{last: make(map[string]*slowSample)}
+)
+
+// SetSlowLogger replaces the default slow logger (alog.Printf), nil restores it
+func SetSlowLogger(log func(SlowOperation)) {
+	if log == nil {
+		slowLogger.Store(nil)
+		return
+	}
+	slowLogger.Store(&slowLoggerHolder{log: log})
+}
+
+// loadSlowConfig reads slow_threshold and slow_sample_interval of the section, it's called after the client of the
+// section was (re)built or reloaded, so that reloading the config takes effect
+func loadSlowConfig(app *aa.App, kind PoolKind, section string) {
+	key := poolKey{kind: kind, section: section}
+	threshold, _ := tryGetSectionCfg(app, string(kind), section, "slow_threshold")
+	c := slowConfig{threshold: types.ParseDuration(threshold)}
+	if c.threshold <= 0 {
+		slowConfigs.Delete(key)
+		return
+	}
+	interval, _ := tryGetSectionCfg(app, string(kind), section, "slow_sample_interval")
+	if c.interval = types.ParseDuration(interval); c.interval <= 0 {
+		c.interval = DefaultSlowSampleInterval
+	}
+	slowConfigs.Store(key, c)
+}
+
+func slowThreshold(kind PoolKind, section string) (slowConfig, bool) {
+	c, ok := slowConfigs.Load(poolKey{kind: kind, section: section})
+	if !ok {
+		return slowConfig{}, false
+	}
+	return c.(slowConfig), true
+}
+
+// LogSlow logs the operation if it's slower than the slow_threshold of the section
+func LogSlow(kind PoolKind, section, statement string, args []any, elapsed time.Duration) {
+	c, ok := slowThreshold(kind, section)
+	if !ok || elapsed < c.threshold {
+		return
+	}
+	skipped, ok := slowSamples.allow(string(kind)+"\x00"+section+"\x00"+statement, c.interval)
+	if !ok {
+		return
+	}
+	op := SlowOperation{
+		Kind:      kind,
+		Section:   section,
+		Statement: Redact(statement),
+		Args:      normalizeArgs(args),
+		Elapsed:   elapsed,
+		Threshold: c.threshold,
+		Caller:    slowCaller(),
+		Skipped:   skipped,
+	}
+	if h := slowLogger.Load(); h != nil {
+		h.log(op)
+		return
+	}
+	alog.Printf("%s", op)
+}
+
+type slowSample struct {
+	at      time.Time
+	skipped int
+}
+
+// slowSampler logs a statement at most once per interval
+type slowSampler struct {
+	mtx  sync.Mutex
+	last map[string]*slowSample
+}
+
+func (s *slowSampler) allow(key string, interval time.Duration) (int, bool) {
+	now := time.Now()
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if sample, ok := s.last[key]; ok {
+		if now.Sub(sample.at) < interval {
+			sample.skipped++
+			return 0, false
+		}
+		skipped := sample.skipped
+		sample.at, sample.skipped = now, 0
+		return skipped, true
+	}
+	if len(s.last) >= slowSamplerCap {
+		for k, sample := range s.last {
+			if now.Sub(sample.at) >= interval {
+				delete(s.last, k)
+			}
+		}
+		if len(s.last) >= slowSamplerCap {
+			clear(s.last)
+		}
+	}
+	s.last[key] = &slowSample{at: now}
+	return 0, true
+}
+
+func normalizeArgs(args []any) []string {
+	if len(args) == 0 {
+		return nil
+	}
+	n := min(len(args), slowArgsMaxSize)
+	ret := make([]string, n, n+1)
+	for i, arg := range args[:n] {
+		ret[i] = normalizeArg(arg)
+	}
+	if len(args) > n {
+		ret = append(ret, fmt.Sprintf("...%d more", len(args)-n))
+	}
+	return ret
+}
+
+func normalizeArg(arg any) string {
+	var s string
+	switch v := arg.(type) {
+	case nil:
+		return "NULL"
+	case []byte:
+		return fmt.Sprintf("<%d bytes>", len(v))
+	case string:
+		s = strconv.Quote(truncateArg(v))
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case fmt.Stringer:
+		s = truncateArg(v.String())
+	default:
+		s = truncateArg(fmt.Sprint(v))
+	}
+	return Redact(s)
+}
+
+func truncateArg(s string) string {
+	if len(s) <= slowArgMaxLen {
+		return s
+	}
+	return s[:slowArgMaxLen] + "..."
+}
+
+// isDriverFrame reports whether the function belongs to this module or the client libraries
+func isDriverFrame(fn string) bool {
+	for _, prefix := range []string{
+		"github.com/aarioai/airis-driver/driver.",
+		"github.com/aarioai/airis-driver/driver/",
+		"github.com/redis/go-redis/",
+		"go.mongodb.org/mongo-driver/",
+		"database/sql.",
+		"runtime.",
+	} {
+		if strings.HasPrefix(fn, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// slowCaller returns the first caller outside the driver, e.g. service/user.go:42
+func slowCaller() string {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		f, more := frames.Next()
+		if f.Function != "" && !isDriverFrame(f.Function) {
+			return filepath.Base(filepath.Dir(f.File)) + "/" + filepath.Base(f.File) + ":" + strconv.Itoa(f.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package driver_test
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aarioai/airis-driver/driver"
+	"github.com/aarioai/airis/aa"
+	"github.com/aarioai/airis/aa/aconfig"
+	"github.com/aarioai/airis/aa/acontext"
+)
+
+func TestSlowLog(t *testing.T) {
+	c, err := aconfig.New("./test_config.ini", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := acontext.WithCancel(acontext.Background())
+	app := aa.New(ctx, cancel, c)
+	defer driver.ClosePools(context.Background(), driver.PoolKindRedis)
+
+	var mtx sync.Mutex
+	var ops []driver.SlowOperation
+	driver.SetSlowLogger(func(op driver.SlowOperation) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		ops = append(ops, op)
+	})
+	defer driver.SetSlowLogger(nil)
+
+	rdb, e := driver.NewRedisPool(app, "redis_slow") // slow_threshold = 1ns, unreachable
+	if e != nil {
+		t.Fatal(e.Msg)
+	}
+	for i := 0; i < 3; i++ {
+		rdb.Set(context.Background(), "user:1", "hello", 0)
+	}
+	time.Sleep(250 * time.Millisecond) // slow_sample_interval
+	rdb.Set(context.Background(), "user:1", "hello", 0)
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	if len(ops) != 2 {
+		t.Fatalf("slow operations should be sampled: %+v", ops)
+	}
+	op := ops[1]
+	if op.Kind != driver.PoolKindRedis || op.Section != "redis_slow" || op.Statement != "set user:1" || op.Skipped != 2 {
+		t.Errorf("slow operation: %+v", op)
+	}
+	if !strings.HasSuffix(strings.Split(op.Caller, ":")[0], "slowlog_test.go") {
+		t.Errorf("slow operation caller should be outside the driver: %s", op.Caller)
+	}
+	if len(op.Args) == 0 || op.Args[0] != `"hello"` || op.Threshold != time.Nanosecond {
+		t.Errorf("slow operation args: %+v", op)
+	}
+}
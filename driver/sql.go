@@ -0,0 +1,156 @@
+package driver
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"time"
+
+	"github.com/aarioai/airis/aa"
+	"github.com/aarioai/airis/aa/ae"
+	"github.com/aarioai/airis/aa/alog"
+	"github.com/aarioai/airis/aa/atype"
+	"github.com/aarioai/airis/pkg/types"
+	"github.com/aarioai/airis/pkg/utils"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"modernc.org/sqlite"
+)
+
+const (
+	sqlBadConnMsg   = "sql bad conn: "
+	sqlSkipMsg      = "sql skip: "
+	sqlRemoveArgMsg = "sql remove argument: "
+	sqlConnDoneMsg  = "sql conn done: "
+	sqlTxDoneMsg    = "sql tx done: "
+	sqlErrorMsg     = "sql error: "
+
+	sqlDeadlockMsg        = "sql deadlock: "
+	sqlLockWaitTimeoutMsg = "sql lock wait timeout: "
+	sqlRowReferencedMsg   = "sql foreign key, row is referenced: "
+	sqlNoReferencedRowMsg = "sql foreign key, referenced row not found: "
+	sqlDataTooLongMsg     = "sql data too long: "
+	sqlNoSuchTableMsg     = "sql table not found: "
+	sqlLostConnMsg        = "sql lost connection: "
+)
+
+// SqlPoolOptions database/sql 连接池配置，mysql/postgres/sqlite 共用
+type SqlPoolOptions struct {
+	MaxIdleConns    int
+	MaxOpenConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+// SqlClientData is the pooled client of mysql, postgres and sqlite sections
+type SqlClientData struct {
+	Client *sql.DB
+	Schema string
+}
+
+// ParseSqlPoolOptions reads pool_max_idle_conns, pool_max_open_conns, pool_conn_max_life_time and
+// pool_conn_max_idle_time of the section
+func ParseSqlPoolOptions(app *aa.App, base, section string) SqlPoolOptions {
+	maxIdleConns, _ := tryGetSectionCfg(app, base, section, "pool_max_idle_conns")
+	maxOpenConns, _ := tryGetSectionCfg(app, base, section, "pool_max_open_conns")
+	connMaxLifetime, _ := tryGetSectionCfg(app, base, section, "pool_conn_max_life_time")
+	connMaxIdleTime, _ := tryGetSectionCfg(app, base, section, "pool_conn_max_idle_time")
+	newV := atype.New()
+	defer newV.Close()
+	return SqlPoolOptions{
+		MaxIdleConns:    newV.Reload(maxIdleConns).DefaultInt(0),
+		MaxOpenConns:    newV.Reload(maxOpenConns).DefaultInt(0),
+		ConnMaxLifetime: types.ParseDuration(connMaxLifetime),
+		ConnMaxIdleTime: types.ParseDuration(connMaxIdleTime),
+	}
+}
+
+func (o SqlPoolOptions) apply(db *sql.DB) {
+	// It is rare to Close a db, as the db handle is meant to be long-lived and shared between many goroutines.
+	db.SetMaxIdleConns(o.MaxIdleConns) // 设置闲置的连接数
+	db.SetMaxOpenConns(o.MaxOpenConns) // 设置最大打开的连接数，默认值为0表示不限制
+	db.SetConnMaxLifetime(o.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(o.ConnMaxIdleTime)
+}
+
+// sqlPoolOptionsOf returns the pool options of a parsed mysql/postgres/sqlite config
+func sqlPoolOptionsOf(config any) SqlPoolOptions {
+	switch f := config.(type) {
+	case MysqlOptions:
+		return f.Pool
+	case PostgresOptions:
+		return f.Pool
+	case SqliteOptions:
+		return f.Pool
+	}
+	return SqlPoolOptions{}
+}
+
+func closeSqlClient(kind PoolKind) func(ctx context.Context, section string, d SqlClientData) error {
+	return func(ctx context.Context, section string, d SqlClientData) error {
+		alog.Stopf("%s client: %s", kind, section)
+		return d.Client.Close()
+	}
+}
+
+// NewSqlError converts the error of database/sql to *ae.Error, it classifies MySQL, PostgreSQL and SQLite errors
+func NewSqlError(err error, details ...any) *ae.Error {
+	return newSqlError(utils.Caller(1), err, details)
+}
+
+func newSqlError(caller string, err error, details []any) *ae.Error {
+	if err == nil {
+		return nil
+	}
+	msg := Redact(err.Error())
+	details = redactDetails(details)
+
+	// 连接断开（CR_SERVER_GONE_ERROR/CR_SERVER_LOST 由 go-sql-driver 以这两个错误返回）
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) {
+		return newMysqlLostConnError(caller, msg, details)
+	}
+
+	errorMapping := map[error]func() *ae.Error{
+		driver.ErrSkip:           func() *ae.Error { return ae.NewError(caller + sqlSkipMsg + msg).WithDetail(details...) },
+		driver.ErrRemoveArgument: func() *ae.Error { return ae.NewError(caller + sqlRemoveArgMsg + msg).WithDetail(details...) },
+		sql.ErrNoRows:            func() *ae.Error { return ae.ErrorNotFound }, // can't WithDetail, locked
+		sql.ErrConnDone:          func() *ae.Error { return ae.NewError(caller + sqlConnDoneMsg + msg).WithDetail(details...) },
+		sql.ErrTxDone:            func() *ae.Error { return ae.NewError(caller + sqlTxDoneMsg + msg).WithDetail(details...) },
+	}
+
+	for errType, handler := range errorMapping {
+		if errors.Is(err, errType) {
+			return handler()
+		}
+	}
+	if errors.Is(err, ErrCircuitOpen) {
+		return ErrorCircuitOpen
+	}
+
+	var me *mysql.MySQLError
+	if errors.As(err, &me) {
+		if e := newMysqlNumberError(me, caller, msg, details); e != nil {
+			return e
+		}
+	}
+	var pe *pgconn.PgError
+	if errors.As(err, &pe) {
+		if e := newPostgresCodeError(pe, caller, msg, details); e != nil {
+			return e
+		}
+	}
+	var se *sqlite.Error
+	if errors.As(err, &se) {
+		if e := newSqliteCodeError(se, caller, msg, details); e != nil {
+			return e
+		}
+	}
+
+	// 处理重复键错误
+	if matches := duplicateKeyPattern.FindStringSubmatch(msg); len(matches) == 3 {
+		return ae.NewConflict("sql key").WithDetail(append([]any{duplicateKeyDetail(matches)}, details...)...)
+	}
+
+	return ae.NewError(caller + sqlErrorMsg + msg).WithDetail(details...)
+}
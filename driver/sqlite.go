@@ -0,0 +1,170 @@
+package driver
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aarioai/airis/aa"
+	"github.com/aarioai/airis/aa/ae"
+	"github.com/aarioai/airis/aa/alog"
+	"github.com/aarioai/airis/pkg/types"
+	"modernc.org/sqlite"
+)
+
+const (
+	SqliteMemory             = ":memory:"
+	DefaultSqliteBusyTimeout = 5 * time.Second
+)
+
+// SQLite result codes, see https://www.sqlite.org/rescode.html
+const (
+	sqliteErrBusy                 = 5
+	sqliteErrLocked               = 6
+	sqliteErrTooBig               = 18
+	sqliteErrConstraintForeignKey = 787
+	sqliteErrConstraintPrimaryKey = 1555
+	sqliteErrConstraintUnique     = 2067
+)
+
+// SqliteOptions https://pkg.go.dev/modernc.org/sqlite, pure go, no cgo
+type SqliteOptions struct {
+	Path        string        // 数据库文件，:memory: 为内存库；也可以是带参数的 URI，如 file:/data/app.db?mode=ro
+	BusyTimeout time.Duration // 等待其他连接释放锁的时间，默认 5s
+	JournalMode string        // 如 WAL，默认不修改
+	ForeignKeys bool
+
+	Pool SqlPoolOptions
+}
+
+// DSN SQLite URI filename, the options are run as PRAGMA on every new connection
+func (f SqliteOptions) DSN() string {
+	path, rawQuery := f.Path, ""
+	if strings.HasPrefix(path, "file:") {
+		// 已经是 URI，保留其参数
+		path, rawQuery, _ = strings.Cut(strings.TrimPrefix(path, "file:"), "?")
+	} else {
+		// 文件名中的 ? # % 等需要转义
+		path = (&url.URL{Path: path}).EscapedPath()
+	}
+	q, _ := url.ParseQuery(rawQuery)
+	q.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", f.BusyTimeout.Milliseconds()))
+	if f.JournalMode != "" {
+		q.Add("_pragma", "journal_mode("+f.JournalMode+")")
+	}
+	if f.ForeignKeys {
+		q.Add("_pragma", "foreign_keys(1)")
+	}
+	return "file:" + path + "?" + q.Encode()
+}
+
+func (f SqliteOptions) isMemory() bool {
+	return f.Path == SqliteMemory || strings.HasPrefix(f.Path, "file:"+SqliteMemory) || strings.Contains(f.Path, "mode=memory")
+}
+
+// NewSqlite
+// Note: better use NewSqlitePool instead
+func NewSqlite(app *aa.App, section string) (string, *sql.DB, *ae.Error) {
+	f, err := ParseSqliteConfig(app, section)
+	if err != nil {
+		return "", nil, newConfigError(section, err)
+	}
+	db, e := openSqlite(f)
+	if e != nil {
+		return "", nil, e
+	}
+	return "main", db, nil
+}
+
+func openSqlite(f SqliteOptions) (*sql.DB, *ae.Error) {
+	alog.Printf("connect sqlite: %s", f.Path)
+	db, err := sql.Open("sqlite", f.DSN())
+	if err != nil {
+		return nil, NewSqlError(err, "open sqlite: "+f.Path)
+	}
+	f.Pool.apply(db)
+	return db, nil
+}
+
+// NewSqlitePool
+// Warning: Do not unset the returned client as it is managed by the pool
+func NewSqlitePool(app *aa.App, section string) (string, *sql.DB, *ae.Error) {
+	d, e := loadOrNewPool(app, PoolKindSqlite, section, func() (SqliteOptions, *ae.Error) {
+		f, err := ParseSqliteConfig(app, section)
+		if err != nil {
+			return f, newConfigError(section, err)
+		}
+		return f, nil
+	}, func(f SqliteOptions) (SqlClientData, *ae.Error) {
+		db, e := openSqlite(f)
+		if e != nil {
+			return SqlClientData{}, e
+		}
+		return SqlClientData{Client: db, Schema: "main"}, nil
+	}, func() {
+		loadSlowConfig(app, PoolKindSqlite, section)
+		loadRetryPolicy(app, PoolKindSqlite, section)
+	}, closeSqlClient(PoolKindSqlite))
+	if e != nil {
+		return "", nil, e
+	}
+	return d.Schema, d.Client, nil
+}
+
+func ParseSqliteConfig(app *aa.App, section string) (SqliteOptions, error) {
+	defer beginSecretPass()()
+	if section == "" {
+		section = "sqlite"
+	}
+	// path 不经过 ResolveSecret，否则 file: URI 会被当作密钥文件读取
+	path, err := rawSectionCfg(app, "sqlite", section, "path")
+	if err != nil {
+		return SqliteOptions{}, err
+	}
+	busyTimeout, _ := tryGetSectionCfg(app, "sqlite", section, "busy_timeout")
+	journalMode, _ := tryGetSectionCfg(app, "sqlite", section, "journal_mode")
+	foreignKeys, _ := tryGetSectionCfg(app, "sqlite", section, "foreign_keys")
+	f := SqliteOptions{
+		Path:        path,
+		BusyTimeout: types.ParseDuration(busyTimeout, DefaultSqliteBusyTimeout),
+		JournalMode: strings.ToUpper(journalMode),
+		ForeignKeys: types.ToBool(foreignKeys),
+		Pool:        ParseSqlPoolOptions(app, "sqlite", section),
+	}
+	if f.isMemory() {
+		// 内存库每个连接各自独立，关闭最后一个连接即丢失，所以只用一个常驻连接
+		f.Pool = SqlPoolOptions{MaxIdleConns: 1, MaxOpenConns: 1}
+	}
+	return f, nil
+}
+
+// newSqliteCodeError maps the SQLite result code to ae code, like newMysqlNumberError
+func newSqliteCodeError(se *sqlite.Error, caller, msg string, details []any) *ae.Error {
+	switch se.Code() {
+	case sqliteErrConstraintUnique, sqliteErrConstraintPrimaryKey:
+		return ae.NewConflict("sql key").WithDetail(details...)
+	case sqliteErrConstraintForeignKey:
+		// 不区分是被引用还是引用的行不存在
+		return ae.New(ae.Conflict, caller+sqlErrorMsg+msg).WithDetail(details...)
+	}
+	// 扩展码的低 8 位为主码
+	switch se.Code() & 0xff {
+	case sqliteErrBusy, sqliteErrLocked:
+		return ae.New(ae.Locked, caller+sqlLockWaitTimeoutMsg+msg).WithDetail(details...)
+	case sqliteErrTooBig:
+		return ae.New(ae.RequestEntityTooLarge, caller+sqlDataTooLongMsg+msg).WithDetail(details...)
+	}
+	return nil
+}
+
+func isRetryableSqliteError(err error) bool {
+	var se *sqlite.Error
+	if !errors.As(err, &se) {
+		return false
+	}
+	code := se.Code() & 0xff
+	return code == sqliteErrBusy || code == sqliteErrLocked
+}
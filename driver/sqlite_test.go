@@ -0,0 +1,64 @@
+package driver_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aarioai/airis-driver/driver"
+	"github.com/aarioai/airis/aa"
+	"github.com/aarioai/airis/aa/aconfig"
+	"github.com/aarioai/airis/aa/acontext"
+	"github.com/aarioai/airis/aa/ae"
+)
+
+func TestSqliteDSN(t *testing.T) {
+	f := driver.SqliteOptions{Path: "/data/a?b#c.db", BusyTimeout: time.Second}
+	if got, want := f.DSN(), "file:/data/a%3Fb%23c.db?_pragma=busy_timeout%281000%29"; got != want {
+		t.Errorf("sqlite dsn %s, want %s", got, want)
+	}
+	f = driver.SqliteOptions{Path: "file:/data/app.db?mode=ro&cache=shared", BusyTimeout: time.Second, ForeignKeys: true}
+	if got, want := f.DSN(), "file:/data/app.db?_pragma=busy_timeout%281000%29&_pragma=foreign_keys%281%29&cache=shared&mode=ro"; got != want {
+		t.Errorf("sqlite uri dsn %s, want %s", got, want)
+	}
+}
+
+func TestSqliteError(t *testing.T) {
+	c, err := aconfig.New("./test_config.ini", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := acontext.WithCancel(acontext.Background())
+	app := aa.New(ctx, cancel, c)
+	defer driver.ClosePools(context.Background(), driver.PoolKindSqlite)
+
+	_, db, e := driver.NewSqlitePool(app, "sqlite")
+	if e != nil {
+		t.Fatal(e)
+	}
+	if err = driver.PingPool(context.Background(), driver.PoolKindSqlite, "sqlite"); err != nil {
+		t.Fatal(err)
+	}
+	for _, qs := range []string{
+		"CREATE TABLE parent (id INTEGER PRIMARY KEY)",
+		"CREATE TABLE child (id INTEGER PRIMARY KEY, parent_id INTEGER REFERENCES parent(id))",
+		"INSERT INTO parent (id) VALUES (1)",
+	} {
+		if _, err = db.Exec(qs); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err = db.Exec("INSERT INTO parent (id) VALUES (1)")
+	if e = driver.NewMysqlError(err); e == nil || e.Code != ae.Conflict {
+		t.Errorf("sqlite duplicate key should be conflict, got %v", e)
+	}
+	// foreign_keys = true
+	_, err = db.Exec("INSERT INTO child (id, parent_id) VALUES (1, 2)")
+	if e = driver.NewMysqlError(err); e == nil || e.Code != ae.Conflict {
+		t.Errorf("sqlite foreign key should be conflict, got %v", e)
+	}
+	if driver.IsRetryable(err) {
+		t.Error("sqlite constraint error should not be retryable")
+	}
+}
@@ -14,7 +14,7 @@ const (
 	StrUint64 = "18446744073709551615"
 )
 
-// 用作 ON DUPLICATE KEY UPDATE v=CASE .. v+VALUES(v) .. END
+// 用作 ON DUPLICATE KEY UPDATE / ON CONFLICT DO UPDATE v=CASE .. v+new_tb.v .. END
 type SafeDupIncrN struct {
 	Field string
 	Min   string
@@ -39,6 +39,11 @@ func DupUint64N(field string) SafeDupIncrN {
 
 // @warn 如果类型是 unsigned ，一定要使用 INSERT IGNORE ，否则若值为负数，则直接报错，走不到 ON DUPLICATE KEY UPDATE 部分
 func SafeDupIncrs(tb string, ns []SafeDupIncrN) string {
+	return SafeDupIncrsOf(MySQL, tb, ns)
+}
+
+// SafeDupIncrsOf e.g. "INSERT INTO tb (...) VALUES (...) " + d.Upsert(tb, "id") + SafeDupIncrsOf(d, tb, ns)
+func SafeDupIncrsOf(d Dialect, tb string, ns []SafeDupIncrN) string {
 	var s strings.Builder
 	s.Grow(len(ns) * 168)
 	for i, n := range ns {
@@ -46,7 +51,7 @@ func SafeDupIncrs(tb string, ns []SafeDupIncrN) string {
 			s.WriteByte(',')
 		}
 		old := tb + "." + n.Field
-		ntb := "new_" + tb + "." + n.Field
+		ntb := d.Inserted(tb, n.Field)
 		// recmds=CASE WHEN tb.recmds-$MIN<=-new_tb.recmds THEN $MIN WHEN new_tb.recmds>=$MAX-tb.recmds THEN $MAX ELSE tb.recmds+new_tb.recmds END
 		s.WriteString(n.Field)
 		s.WriteString("=CASE WHEN ")
@@ -116,11 +121,3 @@ func SafeIncrUint(field string, n int) string {
 func SafeIncrUint64(field string, n int) string {
 	return SafeIncr(field, n, StrUint64)
 }
-
-func toMySqlFieldName(k string) string {
-	fields := strings.Split(k, ".")
-	for i, field := range fields {
-		fields[i] = "`" + field + "`"
-	}
-	return strings.Join(fields, ".")
-}
@@ -1,7 +1,6 @@
 package sqlx
 
 import (
-	"fmt"
 	"strings"
 )
 
@@ -57,39 +56,46 @@ func MakeASQL(v string) ASQL {
 }
 
 func (q ASQL) Fmt(k string) string {
+	return q.FmtOf(MySQL, k)
+}
+
+// FmtOf formats the condition of field k in the syntax of dialect d
+func (q ASQL) FmtOf(d Dialect, k string) string {
+	f := d.Quote(k)
 	if q.EqualTo != "" {
-		return fmt.Sprintf(`%s="%s"`, toMySqlFieldName(k), q.EqualTo)
+		return f + "=" + d.Literal(q.EqualTo)
 	} else if q.UnequalTo != "" {
-		return fmt.Sprintf(`%s!="%s"`, toMySqlFieldName(k), q.EqualTo)
+		return f + "!=" + d.Literal(q.UnequalTo)
 	} else if q.Like != "" {
-		return fmt.Sprintf(`%s LIKE "%%%s%%"`, toMySqlFieldName(k), q.Like)
+		return f + " LIKE " + d.Literal("%"+q.Like+"%")
 	} else if len(q.In) > 0 {
-		r := ""
-		ins := strings.Join(q.In, `","`)
-		r += fmt.Sprintf(`%s IN ("%s")`, toMySqlFieldName(k), ins)
-		return r
+		ins := make([]string, len(q.In))
+		for i, v := range q.In {
+			ins[i] = d.Literal(v)
+		}
+		return f + " IN (" + strings.Join(ins, ",") + ")"
 	} else if q.GreaterOrEqualTo != "" {
 		if q.LessThan != "" {
-			return fmt.Sprintf(`%s>="%s" AND %s<"%s"`, toMySqlFieldName(k), q.GreaterOrEqualTo, toMySqlFieldName(k), q.LessThan)
+			return f + ">=" + d.Literal(q.GreaterOrEqualTo) + " AND " + f + "<" + d.Literal(q.LessThan)
 		} else if q.LessOrEqualTo != "" {
-			return fmt.Sprintf(`%s>="%s" AND %s<="%s"`, toMySqlFieldName(k), q.GreaterOrEqualTo, toMySqlFieldName(k), q.LessOrEqualTo)
+			return f + ">=" + d.Literal(q.GreaterOrEqualTo) + " AND " + f + "<=" + d.Literal(q.LessOrEqualTo)
 		}
-		return fmt.Sprintf(`%s>="%s"`, toMySqlFieldName(k), q.GreaterOrEqualTo)
+		return f + ">=" + d.Literal(q.GreaterOrEqualTo)
 	} else if q.LessThan != "" {
-		return fmt.Sprintf(`%s<"%s"`, toMySqlFieldName(k), q.LessThan)
+		return f + "<" + d.Literal(q.LessThan)
 	} else if q.GreaterThan != "" {
 		if q.LessThan != "" {
-			return fmt.Sprintf(`%s>"%s" AND %s<"%s"`, toMySqlFieldName(k), q.GreaterThan, toMySqlFieldName(k), q.LessThan)
+			return f + ">" + d.Literal(q.GreaterThan) + " AND " + f + "<" + d.Literal(q.LessThan)
 		} else if q.LessOrEqualTo != "" {
-			return fmt.Sprintf(`%s>"%s" AND %s<="%s"`, toMySqlFieldName(k), q.GreaterThan, toMySqlFieldName(k), q.LessOrEqualTo)
+			return f + ">" + d.Literal(q.GreaterThan) + " AND " + f + "<=" + d.Literal(q.LessOrEqualTo)
 		}
-		return fmt.Sprintf(`%s>"%s"`, toMySqlFieldName(k), q.GreaterThan)
+		return f + ">" + d.Literal(q.GreaterThan)
 	} else if q.LessOrEqualTo != "" {
-		return fmt.Sprintf(`%s<="%s"`, toMySqlFieldName(k), q.LessOrEqualTo)
+		return f + "<=" + d.Literal(q.LessOrEqualTo)
 	} else if q.StartWith != "" {
-		return fmt.Sprintf(`%s LIKE "%s%%"`, toMySqlFieldName(k), q.StartWith)
+		return f + " LIKE " + d.Literal(q.StartWith+"%")
 	} else if q.EndWith != "" {
-		return fmt.Sprintf(`%s LIKE "%%%s"`, toMySqlFieldName(k), q.EndWith)
+		return f + " LIKE " + d.Literal("%"+q.EndWith)
 	}
 	return ""
 }
@@ -4,7 +4,6 @@ import (
 	"strings"
 
 	"github.com/aarioai/airis/aa/atype"
-	"github.com/aarioai/airis/pkg/types"
 )
 
 type Cond struct {
@@ -12,10 +11,29 @@ type Cond struct {
 	orderby    string
 	offset     uint
 	limit      uint16
+	dialect    Dialect
 }
 
 func NewCond(paging atype.Paging) *Cond {
-	return &Cond{offset: paging.Offset, limit: paging.Limit}
+	return &Cond{offset: paging.Offset, limit: paging.Limit, dialect: MySQL}
+}
+
+// NewCond returns a Cond in the dialect of the DB
+func (d *DB) NewCond(paging atype.Paging) *Cond {
+	return NewCond(paging).WithDialect(d.dialect)
+}
+
+// WithDialect sets the syntax of the conditions and LIMIT written after it, MySQL by default
+func (c *Cond) WithDialect(d Dialect) *Cond {
+	c.dialect = d
+	return c
+}
+
+func (c *Cond) dialectOf() Dialect {
+	if c.dialect == nil {
+		return MySQL
+	}
+	return c.dialect
 }
 func (c *Cond) WriteString(s string) *Cond {
 	c.Constraint.WriteByte(' ')
@@ -31,7 +49,7 @@ func (c *Cond) Write(operator, s string) *Cond {
 	return c.WriteString(operator + s)
 }
 func (c *Cond) Concat(operator, field, asqlGrammar string) *Cond {
-	s := MakeASQL(asqlGrammar).Fmt(field)
+	s := MakeASQL(asqlGrammar).FmtOf(c.dialectOf(), field)
 	if c.Constraint.Len() > 0 {
 		c.Constraint.WriteByte(' ')
 		c.Constraint.WriteString(operator)
@@ -82,10 +100,7 @@ func (c *Cond) LimitN() uint16 {
 }
 
 func (c *Cond) LimitStmt() string {
-	limit := c.LimitN()
-	a := types.FormatUint(c.offset)
-	b := types.FormatUint(limit)
-	return "LIMIT " + a + "," + b
+	return c.dialectOf().Limit(c.offset, c.LimitN())
 }
 
 func (c *Cond) OrderByStmt() string {
@@ -0,0 +1,152 @@
+package sqlx
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/aarioai/airis-driver/driver"
+	"github.com/aarioai/airis/pkg/types"
+)
+
+// DefaultIdColumn is the auto increment column returned by Insert on dialects without LastInsertId, e.g. PostgreSQL
+const DefaultIdColumn = "id"
+
+// Dialect is the SQL syntax of a database. Queries of DB and Tx are always written with ? placeholders, they are
+// rebound to the placeholders of the dialect before executing.
+type Dialect interface {
+	Name() string
+	// Quote quotes an identifier, e.g. t.id -> `t`.`id` (MySQL) or "t"."id"
+	Quote(identifier string) string
+	// Literal quotes a string literal
+	Literal(s string) string
+	// Placeholder returns the n-th (from 1) placeholder
+	Placeholder(n int) string
+	// Rebind converts the ? placeholders of query to the placeholders of the dialect
+	Rebind(query string) string
+	Limit(offset uint, limit uint16) string
+	// WhereOne restricts an UPDATE/DELETE of the quoted table to one of the rows matched by where
+	WhereOne(table, where string) string
+	// Upsert returns the clause following INSERT INTO table (...) VALUES (...), the assignments (e.g. SafeDupIncrsOf)
+	// are appended to it. conflict are the columns of the unique key, they are required except for MySQL.
+	Upsert(table string, conflict ...string) string
+	// Inserted references field of the row proposed for insertion in the upsert assignments
+	Inserted(table, field string) string
+	// Returning returns the clause to return column of the inserted row, "" if LastInsertId is supported
+	Returning(column string) string
+}
+
+var (
+	MySQL      Dialect = mysqlDialect{}
+	PostgreSQL Dialect = postgresDialect{}
+	SQLite     Dialect = sqliteDialect{}
+)
+
+// DialectOf returns the dialect of sections of the pool kind, MySQL by default
+func DialectOf(kind driver.PoolKind) Dialect {
+	switch kind {
+	case driver.PoolKindPostgres:
+		return PostgreSQL
+	case driver.PoolKindSqlite:
+		return SQLite
+	}
+	return MySQL
+}
+
+func quoteIdentifier(identifier string, quote byte) string {
+	q := string(quote)
+	fields := strings.Split(identifier, ".")
+	for i, field := range fields {
+		fields[i] = q + strings.ReplaceAll(field, q, q+q) + q
+	}
+	return strings.Join(fields, ".")
+}
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) Name() string                   { return "mysql" }
+func (mysqlDialect) Quote(identifier string) string { return quoteIdentifier(identifier, '`') }
+
+// Literal 值已经过 defenseInjection 处理
+func (mysqlDialect) Literal(s string) string    { return `"` + s + `"` }
+func (mysqlDialect) Placeholder(int) string     { return "?" }
+func (mysqlDialect) Rebind(query string) string { return query }
+func (mysqlDialect) Limit(offset uint, limit uint16) string {
+	return "LIMIT " + types.FormatUint(offset) + "," + types.FormatUint(limit)
+}
+func (mysqlDialect) WhereOne(table, where string) string { return where + " LIMIT 1" }
+
+// Upsert 使用 MySQL 8.0.19+ 的行别名 new_$table，代替已弃用的 VALUES(field)
+func (mysqlDialect) Upsert(table string, conflict ...string) string {
+	return "AS new_" + table + " ON DUPLICATE KEY UPDATE "
+}
+func (mysqlDialect) Inserted(table, field string) string { return "new_" + table + "." + field }
+func (mysqlDialect) Returning(string) string             { return "" }
+
+// ansiDialect is the syntax shared by PostgreSQL and SQLite
+type ansiDialect struct{}
+
+func (ansiDialect) Quote(identifier string) string { return quoteIdentifier(identifier, '"') }
+func (ansiDialect) Literal(s string) string        { return "'" + strings.ReplaceAll(s, "'", "''") + "'" }
+func (ansiDialect) Limit(offset uint, limit uint16) string {
+	return "LIMIT " + types.FormatUint(limit) + " OFFSET " + types.FormatUint(offset)
+}
+func (d ansiDialect) Upsert(table string, conflict ...string) string {
+	cols := make([]string, len(conflict))
+	for i, c := range conflict {
+		cols[i] = d.Quote(c)
+	}
+	return "ON CONFLICT (" + strings.Join(cols, ",") + ") DO UPDATE SET "
+}
+func (ansiDialect) Inserted(table, field string) string { return "excluded." + field }
+
+type postgresDialect struct{ ansiDialect }
+
+func (postgresDialect) Name() string             { return "postgres" }
+func (postgresDialect) Placeholder(n int) string { return "$" + strconv.Itoa(n) }
+
+// Rebind ? -> $1, $2 ...，跳过引号内的 ?
+func (postgresDialect) Rebind(query string) string {
+	if strings.IndexByte(query, '?') < 0 {
+		return query
+	}
+	var s strings.Builder
+	s.Grow(len(query) + 8)
+	var quote byte
+	n := 0
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '?':
+			n++
+			s.WriteByte('$')
+			s.WriteString(strconv.Itoa(n))
+			continue
+		}
+		s.WriteByte(c)
+	}
+	return s.String()
+}
+
+// WhereOne PostgreSQL 不支持 UPDATE/DELETE ... LIMIT
+func (postgresDialect) WhereOne(table, where string) string {
+	return "ctid = (SELECT ctid FROM " + table + " WHERE " + where + " LIMIT 1)"
+}
+func (d postgresDialect) Returning(column string) string { return " RETURNING " + d.Quote(column) }
+
+type sqliteDialect struct{ ansiDialect }
+
+func (sqliteDialect) Name() string               { return "sqlite" }
+func (sqliteDialect) Placeholder(int) string     { return "?" }
+func (sqliteDialect) Rebind(query string) string { return query }
+
+// WhereOne SQLite 默认编译不支持 UPDATE/DELETE ... LIMIT
+func (sqliteDialect) WhereOne(table, where string) string {
+	return "rowid = (SELECT rowid FROM " + table + " WHERE " + where + " LIMIT 1)"
+}
+func (sqliteDialect) Returning(string) string { return "" }
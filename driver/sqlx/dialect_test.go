@@ -0,0 +1,145 @@
+package sqlx_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aarioai/airis-driver/driver"
+	"github.com/aarioai/airis-driver/driver/index"
+	"github.com/aarioai/airis-driver/driver/sqlx"
+	"github.com/aarioai/airis/aa"
+	"github.com/aarioai/airis/aa/aconfig"
+	"github.com/aarioai/airis/aa/acontext"
+	"github.com/aarioai/airis/aa/ae"
+	"github.com/aarioai/airis/aa/atype"
+)
+
+func TestDialect(t *testing.T) {
+	cond := (&sqlx.Cond{}).WithDialect(sqlx.PostgreSQL)
+	cond.And("t.id", "100").And("name", ":A,B").Try("t.id DESC", 40, 20)
+	if want := ` WHERE "t"."id"='100' AND "name" IN ('A','B') ORDER BY t.id DESC LIMIT 20 OFFSET 40`; cond.Stmt() != want {
+		t.Errorf("postgres cond `%s`, want `%s`", cond.Stmt(), want)
+	}
+	if got := sqlx.PostgreSQL.Rebind("SELECT '?', \"a?\" FROM t WHERE a=? AND b IN (?,?)"); got != "SELECT '?', \"a?\" FROM t WHERE a=$1 AND b IN ($2,$3)" {
+		t.Errorf("postgres rebind `%s`", got)
+	}
+	if got := sqlx.MySQL.Rebind("a=?"); got != "a=?" {
+		t.Errorf("mysql rebind `%s`", got)
+	}
+
+	ns := []sqlx.SafeDupIncrN{sqlx.DupUint8N("views")}
+	if got := sqlx.SafeDupIncrs("stats", ns); got != sqlx.SafeDupIncrsOf(sqlx.MySQL, "stats", ns) ||
+		got != "views=CASE WHEN stats.views-0<=-new_stats.views THEN 0 WHEN new_stats.views>=255-stats.views THEN 255 ELSE stats.views+new_stats.views END" {
+		t.Errorf("mysql SafeDupIncrs `%s`", got)
+	}
+	if got := sqlx.SQLite.Upsert("stats", "id"); got != `ON CONFLICT ("id") DO UPDATE SET ` {
+		t.Errorf("sqlite upsert `%s`", got)
+	}
+}
+
+type dialectUser struct{}
+
+func (dialectUser) Table() string          { return "users" }
+func (dialectUser) Indexes() index.Indexes { return index.NewIndexes(index.Primary("id")) }
+
+func TestSqliteDB(t *testing.T) {
+	c, err := aconfig.New("../test_config.ini", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := acontext.WithCancel(acontext.Background())
+	app := aa.New(ctx, cancel, c)
+	defer driver.ClosePools(context.Background(), driver.PoolKindSqlite)
+
+	bg := context.Background()
+	d := sqlx.NewSqliteDB(app, "sqlite")
+	if d.Dialect() != sqlx.SQLite {
+		t.Fatalf("dialect %s", d.Dialect().Name())
+	}
+	if e := d.Exec(bg, "CREATE TABLE users (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL UNIQUE, views INTEGER NOT NULL DEFAULT 0)"); e != nil {
+		t.Fatal(e)
+	}
+	var ids []uint
+	for _, name := range []string{"Aario", "Tom", "Lucy"} {
+		id, e := d.Insert(bg, "INSERT INTO users (name) VALUES (?)", name)
+		if e != nil {
+			t.Fatal(e)
+		}
+		ids = append(ids, id)
+	}
+	if ids[0] != 1 || ids[2] != 3 {
+		t.Errorf("insert ids %v", ids)
+	}
+	if _, e := d.Insert(bg, "INSERT INTO users (name) VALUES (?)", "Tom"); e == nil || e.Code != ae.Conflict {
+		t.Errorf("duplicate insert should be conflict, got %v", e)
+	}
+
+	orm := d.ORM(dialectUser{})
+	if e := orm.Alter(bg, ids[1], map[string]any{"name": "Tommy"}); e != nil {
+		t.Fatal(e)
+	}
+	var name string
+	if e := orm.Find(bg, ids[1], map[string]any{"name": &name}); e != nil || name != "Tommy" {
+		t.Errorf("find altered user: %s %v", name, e)
+	}
+	if e := orm.DeletePK(bg, ids[2]); e != nil {
+		t.Fatal(e)
+	}
+	if e := orm.ExistsPK(bg, ids[2]); e == nil || e.Code != ae.NotFound {
+		t.Errorf("deleted user exists: %v", e)
+	}
+
+	// upsert 自增，不超过 uint8
+	ns := []sqlx.SafeDupIncrN{sqlx.DupUint8N("views")}
+	qs := "INSERT INTO users (name, views) VALUES (?, ?) " + d.Dialect().Upsert("users", "name") + sqlx.SafeDupIncrsOf(d.Dialect(), "users", ns)
+	for _, n := range []int{200, 100} {
+		if e := d.Exec(bg, qs, "Aario", n); e != nil {
+			t.Fatal(e)
+		}
+	}
+	var views int
+	if e := d.ScanX(bg, "SELECT views FROM users WHERE name=?", "Aario", &views); e != nil || views != 255 {
+		t.Errorf("upsert views %d %v", views, e)
+	}
+
+	cond := d.NewCond(atype.Paging{Offset: 1, Limit: 10})
+	cond.And("name", ":Aario,Tommy").OrderBy("id")
+	rows, e := d.Query(bg, "SELECT name FROM users"+cond.Stmt())
+	if e != nil {
+		t.Fatal(e)
+	}
+	var names []string
+	for rows.Next() {
+		if err = rows.Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, name)
+	}
+	rows.Close()
+	if len(names) != 1 || names[0] != "Tommy" {
+		t.Errorf("cond query %v", names)
+	}
+}
+
+func TestPoolDBReconnect(t *testing.T) {
+	c, err := aconfig.New("../test_config.ini", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := acontext.WithCancel(acontext.Background())
+	app := aa.New(ctx, cancel, c)
+	defer driver.ClosePools(context.Background(), driver.PoolKindSqlite)
+
+	bg := context.Background()
+	d := sqlx.NewSqliteDB(app, "sqlite")
+	// 连接池关闭后，下次调用重新连接，而不是使用已关闭的 *sql.DB
+	if err = driver.ClosePool(bg, driver.PoolKindSqlite, "sqlite"); err != nil {
+		t.Fatal(err)
+	}
+	if e := d.Exec(bg, "CREATE TABLE t (id INTEGER PRIMARY KEY)"); e != nil {
+		t.Fatal(e)
+	}
+	if _, ok := driver.PoolClient[driver.SqlClientData](driver.PoolKindSqlite, "sqlite"); !ok {
+		t.Error("sqlite pool should be rebuilt")
+	}
+}
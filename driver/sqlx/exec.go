@@ -7,24 +7,87 @@ import (
 	"fmt"
 
 	"github.com/aarioai/airis-driver/driver"
+	"github.com/aarioai/airis/aa"
 	"github.com/aarioai/airis/aa/ae"
 	"github.com/aarioai/airis/aa/alog"
 )
 
 type DB struct {
-	Schema string
-	DB     *sql.DB
-	error  *ae.Error
+	Schema   string
+	DB       *sql.DB
+	error    *ae.Error
+	app      *aa.App
+	pool     func(app *aa.App, section string) (string, *sql.DB, *ae.Error)
+	section  string
+	replicas *replicaSet
+	kind     driver.PoolKind
+	dialect  Dialect
 }
 
+// NewDriver returns a MySQL DB, use WithDialect for *sql.DB of other databases
 func NewDriver(schema string, db *sql.DB, e *ae.Error) *DB {
 	return &DB{
-		Schema: schema,
-		DB:     db,
-		error:  e,
+		Schema:  schema,
+		DB:      db,
+		error:   e,
+		kind:    driver.PoolKindMysql,
+		dialect: MySQL,
 	}
 }
 
+// NewDB returns a DB of the pooled section. The pooled client is looked up on every call, so it follows the new *sql.DB
+// after the section is hot reloaded, and a failed connection is retried by the next call.
+// If the section declares replicas, Query/QueryRow/Scan* go to a healthy replica, while Prepare, Exec/Insert/Update and
+// transactions go to the primary. Use UsePrimary(ctx) to read from the primary.
+func NewDB(app *aa.App, section string) *DB {
+	d := newPoolDB(app, driver.PoolKindMysql, section, driver.NewMysqlPool)
+	d.replicas = newReplicaSet(app, section)
+	return d
+}
+
+// NewPostgresDB is NewDB of a [postgres] section, in PostgreSQL dialect
+func NewPostgresDB(app *aa.App, section string) *DB {
+	return newPoolDB(app, driver.PoolKindPostgres, section, driver.NewPostgresPool)
+}
+
+// NewSqliteDB is NewDB of a [sqlite] section, in SQLite dialect
+func NewSqliteDB(app *aa.App, section string) *DB {
+	return newPoolDB(app, driver.PoolKindSqlite, section, driver.NewSqlitePool)
+}
+
+// newPoolDB binds d to the pooled section, Schema and DB are the client at the time of creation
+func newPoolDB(app *aa.App, kind driver.PoolKind, section string, pool func(*aa.App, string) (string, *sql.DB, *ae.Error)) *DB {
+	schema, db, _ := pool(app, section)
+	return &DB{
+		Schema:  schema,
+		DB:      db,
+		app:     app,
+		pool:    pool,
+		section: section,
+		kind:    kind,
+		dialect: DialectOf(kind),
+	}
+}
+
+// WithDialect sets the dialect of the DB, it's set by NewDB, NewPostgresDB and NewSqliteDB
+func (d *DB) WithDialect(dialect Dialect) *DB {
+	d.dialect = dialect
+	return d
+}
+
+func (d *DB) Dialect() Dialect {
+	return d.dialect
+}
+
+// conn returns the pooled client of the section, or DB if it's not created by NewDB
+func (d *DB) conn() (*sql.DB, *ae.Error) {
+	if d.pool == nil {
+		return d.DB, nil
+	}
+	_, db, e := d.pool(d.app, d.section)
+	return db, e
+}
+
 // 批处理 prepare 性能会更好，但需要支持 sqlx；非批处理，不要使用 prepare，会造成多余开销
 // 不要忘记 stmt.Close() 释放连接池资源
 // Prepared statements take up server resources and should be closed after use.
@@ -32,12 +95,22 @@ func (d *DB) Prepare(ctx context.Context, query string) (*sql.Stmt, *ae.Error) {
 	if d.error != nil {
 		return nil, d.error
 	}
-	stmt, err := d.DB.PrepareContext(ctx, query)
+	query = d.dialect.Rebind(query)
+	db, e := d.conn()
+	if e != nil {
+		return nil, e
+	}
+	ctx, op, e := d.startGuardedOp(ctx, d.section, "prepare", query, nil)
+	if e != nil {
+		return nil, e
+	}
+	stmt, err := db.PrepareContext(ctx, query)
+	op.end(err)
 	if err != nil {
 		if stmt != nil {
 			alog.OnError(stmt.Close())
 		}
-		return nil, driver.NewMysqlError(err, query)
+		return nil, driver.NewSqlError(err, query)
 	}
 	return stmt, nil
 }
@@ -49,8 +122,20 @@ func (d *DB) Execute(ctx context.Context, query string, args ...any) (sql.Result
 	if d.error != nil {
 		return nil, d.error
 	}
-	res, err := d.DB.ExecContext(ctx, query, args...)
-	return res, driver.NewMysqlError(err, query)
+	query = d.dialect.Rebind(query)
+	return driver.Retry(ctx, d.retryPolicy(ctx, true), func(ctx context.Context) (sql.Result, *ae.Error) {
+		db, e := d.conn()
+		if e != nil {
+			return nil, e
+		}
+		ctx, op, e := d.startGuardedOp(ctx, d.section, "exec", query, args)
+		if e != nil {
+			return nil, e
+		}
+		res, err := db.ExecContext(ctx, query, args...)
+		op.endExec(res, err)
+		return res, driver.NewSqlError(err, query)
+	})
 }
 
 func (d *DB) Exec(ctx context.Context, query string, args ...any) *ae.Error {
@@ -61,17 +146,44 @@ func (d *DB) Exec(ctx context.Context, query string, args ...any) *ae.Error {
 	return e
 }
 
+// Insert returns the auto increment id, it's the DefaultIdColumn on dialects without LastInsertId.
+// On PostgreSQL it appends RETURNING "id" to query, so the table must have an id column: use InsertReturning for
+// another column, or Exec if there is none. A row skipped by ON CONFLICT DO NOTHING returns 0, like INSERT IGNORE of
+// MySQL.
 func (d *DB) Insert(ctx context.Context, query string, args ...any) (uint, *ae.Error) {
+	return d.InsertReturning(ctx, DefaultIdColumn, query, args...)
+}
+
+// InsertReturning returns column of the inserted row (RETURNING column) on dialects without LastInsertId, e.g.
+// PostgreSQL, otherwise it returns LastInsertId and column is ignored
+func (d *DB) InsertReturning(ctx context.Context, column, query string, args ...any) (uint, *ae.Error) {
 	if d.error != nil {
 		return 0, d.error
 	}
+	if returning := d.dialect.Returning(column); returning != "" {
+		query = d.dialect.Rebind(query + returning)
+		return driver.Retry(ctx, d.retryPolicy(ctx, true), func(ctx context.Context) (uint, *ae.Error) {
+			db, e := d.conn()
+			if e != nil {
+				return 0, e
+			}
+			ctx, op, e := d.startGuardedOp(ctx, d.section, "exec", query, args)
+			if e != nil {
+				return 0, e
+			}
+			var id uint
+			err := db.QueryRowContext(ctx, query, args...).Scan(&id)
+			op.end(err)
+			return returnedId(id, err, query)
+		})
+	}
 	res, e := d.Execute(ctx, query, args...)
 	if e != nil {
 		return 0, e
 	}
 	// 由于事务是先执行，后回滚或提交，所以可以先获取插入的ID，后commit()
 	id, err := res.LastInsertId()
-	return uint(id), driver.NewMysqlError(err, query)
+	return uint(id), driver.NewSqlError(err, query)
 }
 
 func (d *DB) Update(ctx context.Context, query string, args ...any) (int64, *ae.Error) {
@@ -84,7 +196,15 @@ func (d *DB) Update(ctx context.Context, query string, args ...any) (int64, *ae.
 	}
 	// 由于事务是先执行，后回滚或提交，所以可以先获取更新结果，后commit()
 	id, err := res.RowsAffected()
-	return id, driver.NewMysqlError(err, query)
+	return id, driver.NewSqlError(err, query)
+}
+
+// returnedId returns 0 without error if no row is inserted, e.g. ON CONFLICT DO NOTHING
+func returnedId(id uint, err error, query string) (uint, *ae.Error) {
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return id, driver.NewSqlError(err, query)
 }
 
 // 批量查询
@@ -112,8 +232,27 @@ func (d *DB) QueryRow(ctx context.Context, query string, args ...any) (*sql.Row,
 	if d.error != nil {
 		return nil, d.error
 	}
-	row := d.DB.QueryRowContext(ctx, query, args...)
-	return row, driver.NewMysqlError(row.Err(), query)
+	query = d.dialect.Rebind(query)
+	return driver.Retry(ctx, d.retryPolicy(ctx, false), func(ctx context.Context) (*sql.Row, *ae.Error) {
+		conn, section, e := d.readConn(ctx)
+		if e != nil {
+			return nil, e
+		}
+		opCtx, op, e := d.startGuardedOp(ctx, section, "query_row", query, args)
+		if e != nil {
+			return nil, e
+		}
+		row := conn.QueryRowContext(opCtx, query, args...)
+		op.end(row.Err())
+		if primary, ok := d.readFallback(section, row.Err()); ok {
+			if opCtx, op, e = d.startGuardedOp(ctx, d.section, "query_row", query, args); e != nil {
+				return nil, e
+			}
+			row = primary.QueryRowContext(opCtx, query, args...)
+			op.end(row.Err())
+		}
+		return row, driver.NewSqlError(row.Err(), query)
+	})
 }
 
 func (d *DB) ScanArgs(ctx context.Context, query string, args []any, dest ...any) *ae.Error {
@@ -124,7 +263,7 @@ func (d *DB) ScanArgs(ctx context.Context, query string, args []any, dest ...any
 	if e != nil {
 		return e
 	}
-	return driver.NewMysqlError(row.Scan(dest...), query)
+	return driver.NewSqlError(row.Scan(dest...), query)
 }
 func (d *DB) ScanRow(ctx context.Context, query string, dest ...any) *ae.Error {
 	if d.error != nil {
@@ -134,7 +273,7 @@ func (d *DB) ScanRow(ctx context.Context, query string, dest ...any) *ae.Error {
 	if e != nil {
 		return e
 	}
-	return driver.NewMysqlError(row.Scan(dest...), query)
+	return driver.NewSqlError(row.Scan(dest...), query)
 }
 
 func (d *DB) Scan(ctx context.Context, query string, id uint64, dest ...any) *ae.Error {
@@ -145,7 +284,7 @@ func (d *DB) Scan(ctx context.Context, query string, id uint64, dest ...any) *ae
 	if e != nil {
 		return e
 	}
-	return driver.NewMysqlError(row.Scan(dest...), fmt.Sprintf(query, id))
+	return driver.NewSqlError(row.Scan(dest...), fmt.Sprintf(query, id))
 }
 
 func (d *DB) ScanX(ctx context.Context, query string, id string, dest ...any) *ae.Error {
@@ -156,7 +295,7 @@ func (d *DB) ScanX(ctx context.Context, query string, id string, dest ...any) *a
 	if e != nil {
 		return e
 	}
-	return driver.NewMysqlError(row.Scan(dest...), fmt.Sprintf(query, id))
+	return driver.NewSqlError(row.Scan(dest...), fmt.Sprintf(query, id))
 }
 
 func (d *DB) ScanAny(ctx context.Context, query string, id any, dest ...any) *ae.Error {
@@ -167,7 +306,7 @@ func (d *DB) ScanAny(ctx context.Context, query string, id any, dest ...any) *ae
 	if e != nil {
 		return e
 	}
-	return driver.NewMysqlError(row.Scan(dest...), fmt.Sprintf(query, id))
+	return driver.NewSqlError(row.Scan(dest...), fmt.Sprintf(query, id))
 }
 
 // Query returns a nil result when no rows are found.
@@ -177,15 +316,34 @@ func (d *DB) Query(ctx context.Context, query string, args ...any) (*sql.Rows, *
 	if d.error != nil {
 		return nil, d.error
 	}
-	rows, err := d.DB.QueryContext(ctx, query, args...)
-	if err != nil {
-		if rows != nil {
-			alog.OnError(rows.Close())
+	query = d.dialect.Rebind(query)
+	return driver.Retry(ctx, d.retryPolicy(ctx, false), func(ctx context.Context) (*sql.Rows, *ae.Error) {
+		conn, section, e := d.readConn(ctx)
+		if e != nil {
+			return nil, e
 		}
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, ae.ErrorNoRowsAvailable
+		opCtx, op, e := d.startGuardedOp(ctx, section, "query", query, args)
+		if e != nil {
+			return nil, e
 		}
-		return nil, driver.NewMysqlError(err, query)
-	}
-	return rows, nil
+		rows, err := conn.QueryContext(opCtx, query, args...)
+		op.end(err)
+		if primary, ok := d.readFallback(section, err); ok {
+			if opCtx, op, e = d.startGuardedOp(ctx, d.section, "query", query, args); e != nil {
+				return nil, e
+			}
+			rows, err = primary.QueryContext(opCtx, query, args...)
+			op.end(err)
+		}
+		if err != nil {
+			if rows != nil {
+				alog.OnError(rows.Close())
+			}
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, ae.ErrorNoRowsAvailable
+			}
+			return nil, driver.NewSqlError(err, query)
+		}
+		return rows, nil
+	})
 }
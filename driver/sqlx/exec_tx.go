@@ -14,8 +14,11 @@ import (
 
 type txResult uint8
 type Tx struct {
-	result txResult
-	Tx     *sql.Tx
+	result  txResult
+	Tx      *sql.Tx
+	kind    driver.PoolKind
+	section string
+	dialect Dialect
 }
 
 const (
@@ -27,22 +30,37 @@ func (d *DB) Begin(ctx context.Context, opts *sql.TxOptions) (*Tx, *ae.Error) {
 	if d.error != nil {
 		return nil, d.error
 	}
-	tx, err := d.DB.BeginTx(ctx, opts)
+	db, e := d.conn()
+	if e != nil {
+		return nil, e
+	}
+	ctx, op, e := d.startGuardedOp(ctx, d.section, "begin", "BEGIN", nil)
+	if e != nil {
+		return nil, e
+	}
+	tx, err := db.BeginTx(ctx, opts)
+	op.end(err)
 	if err != nil {
-		return nil, driver.NewMysqlError(err)
+		return nil, driver.NewSqlError(err)
 	}
-	t := Tx{Tx: tx}
+	t := Tx{Tx: tx, kind: d.kind, section: d.traceSection(), dialect: d.dialect}
 	return &t, nil
 }
 
 func (t *Tx) Rollback() *ae.Error {
 	t.result = rollback
-	return driver.NewMysqlError(t.Tx.Rollback())
+	_, op := startOp(context.Background(), t.kind, t.section, "rollback", "ROLLBACK", nil)
+	err := t.Tx.Rollback()
+	op.end(err)
+	return driver.NewSqlError(err)
 }
 
 func (t *Tx) Commit() *ae.Error {
 	t.result = commit
-	return driver.NewMysqlError(t.Tx.Commit())
+	_, op := startOp(context.Background(), t.kind, t.section, "commit", "COMMIT", nil)
+	err := t.Tx.Commit()
+	op.end(err)
+	return driver.NewSqlError(err)
 }
 
 // defer tx.Recover
@@ -59,19 +77,25 @@ func (t *Tx) Recover() func() {
 }
 
 func (t *Tx) Prepare(ctx context.Context, query string) (*sql.Stmt, *ae.Error) {
+	query = t.dialect.Rebind(query)
+	ctx, op := startOp(ctx, t.kind, t.section, "prepare", query, nil)
 	stmt, err := t.Tx.PrepareContext(ctx, query)
+	op.end(err)
 	if err != nil {
 		if stmt != nil {
 			alog.OnError(stmt.Close())
 		}
-		return nil, driver.NewMysqlError(err, query)
+		return nil, driver.NewSqlError(err, query)
 	}
 	return stmt, nil
 }
 
 func (t *Tx) Execute(ctx context.Context, query string, args ...any) (sql.Result, *ae.Error) {
+	query = t.dialect.Rebind(query)
+	ctx, op := startOp(ctx, t.kind, t.section, "exec", query, args)
 	res, err := t.Tx.ExecContext(ctx, query, args...)
-	return res, driver.NewMysqlError(err, query)
+	op.endExec(res, err)
+	return res, driver.NewSqlError(err, query)
 }
 
 func (t *Tx) Exec(ctx context.Context, query string, args ...any) *ae.Error {
@@ -79,14 +103,28 @@ func (t *Tx) Exec(ctx context.Context, query string, args ...any) *ae.Error {
 	return e
 }
 
+// Insert returns the auto increment id, see DB.Insert
 func (t *Tx) Insert(ctx context.Context, query string, args ...any) (uint, *ae.Error) {
+	return t.InsertReturning(ctx, DefaultIdColumn, query, args...)
+}
+
+// InsertReturning see DB.InsertReturning
+func (t *Tx) InsertReturning(ctx context.Context, column, query string, args ...any) (uint, *ae.Error) {
+	if returning := t.dialect.Returning(column); returning != "" {
+		query = t.dialect.Rebind(query + returning)
+		ctx, op := startOp(ctx, t.kind, t.section, "exec", query, args)
+		var id uint
+		err := t.Tx.QueryRowContext(ctx, query, args...).Scan(&id)
+		op.end(err)
+		return returnedId(id, err, query)
+	}
 	res, e := t.Execute(ctx, query, args...)
 	if e != nil {
 		return 0, e
 	}
 	// 由于事务是先执行，后回滚或提交，所以可以先获取插入的ID，后commit()
 	id, err := res.LastInsertId()
-	return uint(id), driver.NewMysqlError(err, query)
+	return uint(id), driver.NewSqlError(err, query)
 }
 
 func (t *Tx) Update(ctx context.Context, query string, args ...any) (int64, *ae.Error) {
@@ -96,7 +134,7 @@ func (t *Tx) Update(ctx context.Context, query string, args ...any) (int64, *ae.
 	}
 	// 由于事务是先执行，后回滚或提交，所以可以先获取更新结果，后commit()
 	id, err := res.RowsAffected()
-	return id, driver.NewMysqlError(err, query)
+	return id, driver.NewSqlError(err, query)
 }
 
 // 批量查询
@@ -120,8 +158,11 @@ func (t *Tx) Update(ctx context.Context, query string, args ...any) (int64, *ae.
 //}
 
 func (t *Tx) QueryRow(ctx context.Context, query string, args ...any) (*sql.Row, *ae.Error) {
+	query = t.dialect.Rebind(query)
+	ctx, op := startOp(ctx, t.kind, t.section, "query_row", query, args)
 	row := t.Tx.QueryRowContext(ctx, query, args...)
-	return row, driver.NewMysqlError(row.Err(), query)
+	op.end(row.Err())
+	return row, driver.NewSqlError(row.Err(), query)
 }
 
 func (t *Tx) ScanArgs(ctx context.Context, query string, args []any, dest ...any) *ae.Error {
@@ -129,7 +170,7 @@ func (t *Tx) ScanArgs(ctx context.Context, query string, args []any, dest ...any
 	if e != nil {
 		return e
 	}
-	return driver.NewMysqlError(row.Scan(dest...), query)
+	return driver.NewSqlError(row.Scan(dest...), query)
 }
 
 func (t *Tx) ScanRow(ctx context.Context, query string, dest ...any) *ae.Error {
@@ -137,7 +178,7 @@ func (t *Tx) ScanRow(ctx context.Context, query string, dest ...any) *ae.Error {
 	if e != nil {
 		return e
 	}
-	return driver.NewMysqlError(row.Scan(dest...), query)
+	return driver.NewSqlError(row.Scan(dest...), query)
 }
 
 func (t *Tx) Scan(ctx context.Context, query string, id uint64, dest ...any) *ae.Error {
@@ -145,7 +186,7 @@ func (t *Tx) Scan(ctx context.Context, query string, id uint64, dest ...any) *ae
 	if e != nil {
 		return e
 	}
-	return driver.NewMysqlError(row.Scan(dest...), fmt.Sprintf(query, id))
+	return driver.NewSqlError(row.Scan(dest...), fmt.Sprintf(query, id))
 }
 
 func (t *Tx) ScanX(ctx context.Context, query string, id string, dest ...any) *ae.Error {
@@ -153,7 +194,7 @@ func (t *Tx) ScanX(ctx context.Context, query string, id string, dest ...any) *a
 	if e != nil {
 		return e
 	}
-	return driver.NewMysqlError(row.Scan(dest...), fmt.Sprintf(query, id))
+	return driver.NewSqlError(row.Scan(dest...), fmt.Sprintf(query, id))
 }
 
 func (t *Tx) ScanAny(ctx context.Context, query string, id any, dest ...any) *ae.Error {
@@ -161,14 +202,17 @@ func (t *Tx) ScanAny(ctx context.Context, query string, id any, dest ...any) *ae
 	if e != nil {
 		return e
 	}
-	return driver.NewMysqlError(row.Scan(dest...), fmt.Sprintf(query, id))
+	return driver.NewSqlError(row.Scan(dest...), fmt.Sprintf(query, id))
 }
 
 // Query returns a nil result when no rows are found.
 // QueryRow returns ae.ErrorNotFound if no rows match the query.
 // do not forget to close *sqlx.Rows
 func (t *Tx) Query(ctx context.Context, query string, args ...any) (*sql.Rows, *ae.Error) {
+	query = t.dialect.Rebind(query)
+	ctx, op := startOp(ctx, t.kind, t.section, "query", query, args)
 	rows, err := t.Tx.QueryContext(ctx, query, args...)
+	op.end(err)
 	if err != nil {
 		if rows != nil {
 			alog.OnError(rows.Close())
@@ -176,7 +220,7 @@ func (t *Tx) Query(ctx context.Context, query string, args ...any) (*sql.Rows, *
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ae.ErrorNoRowsAvailable
 		}
-		return nil, driver.NewMysqlError(err, query)
+		return nil, driver.NewSqlError(err, query)
 	}
 	return rows, nil
 }
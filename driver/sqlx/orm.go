@@ -23,12 +23,15 @@ func (d *DB) ORM(t index.Entity) *ORMS {
 }
 
 func (d *ORMS) DeleteMany(ctx context.Context, field string, value any) *ae.Error {
-	qs := fmt.Sprintf("DELETE FROM `%s` WHERE `%s`=?", d.t.Table(), field)
+	dl := d.db.dialect
+	qs := fmt.Sprintf("DELETE FROM %s WHERE %s=?", dl.Quote(d.t.Table()), dl.Quote(field))
 	return d.db.Exec(ctx, qs, value)
 }
 
 func (d *ORMS) DeleteOne(ctx context.Context, field string, value any) *ae.Error {
-	qs := fmt.Sprintf("DELETE FROM `%s` WHERE `%s`=? LIMIT 1", d.t.Table(), field)
+	dl := d.db.dialect
+	tb := dl.Quote(d.t.Table())
+	qs := fmt.Sprintf("DELETE FROM %s WHERE %s", tb, dl.WhereOne(tb, dl.Quote(field)+"=?"))
 	return d.db.Exec(ctx, qs, value)
 }
 
@@ -41,7 +44,8 @@ func (d *ORMS) DeletePK(ctx context.Context, id any) *ae.Error {
 }
 
 func (d *ORMS) ExistsOne(ctx context.Context, field string, value any) *ae.Error {
-	qs := fmt.Sprintf("SELECT 1 FROM `%s` WHERE `%s`=? LIMIT 1", d.t.Table(), field)
+	dl := d.db.dialect
+	qs := fmt.Sprintf("SELECT 1 FROM %s WHERE %s=? LIMIT 1", dl.Quote(d.t.Table()), dl.Quote(field))
 	var newId uint8
 	e := d.db.ScanAny(ctx, qs, value, &newId)
 	if e != nil {
@@ -61,25 +65,29 @@ func (d *ORMS) ExistsPK(ctx context.Context, id any) *ae.Error {
 	return d.ExistsOne(ctx, primary, id)
 }
 
-func (d *ORMS) AlterMany(ctx context.Context, field string, value any, data map[string]any) *ae.Error {
-	if len(data) == 0 {
-		return ae.ErrorInputTooShort
-	}
-
+// assignments returns `k1`=?,`k2`=? and the values
+func (d *ORMS) assignments(data map[string]any, capacity int) (string, []any) {
 	var s strings.Builder
-	args := make([]any, 0, len(data)+1)
+	args := make([]any, 0, capacity)
 	for k, v := range data {
 		args = append(args, v)
 		if s.Len() > 0 {
 			s.WriteString(",")
 		}
-		s.WriteByte('`')
-		s.WriteString(k)
-		s.WriteByte('`')
+		s.WriteString(d.db.dialect.Quote(k))
 		s.WriteString("=?")
 	}
+	return s.String(), args
+}
+
+func (d *ORMS) AlterMany(ctx context.Context, field string, value any, data map[string]any) *ae.Error {
+	if len(data) == 0 {
+		return ae.ErrorInputTooShort
+	}
+	dl := d.db.dialect
+	set, args := d.assignments(data, len(data)+1)
 	args = append(args, value)
-	qs := fmt.Sprintf("UPDATE `%s` SET %s WHERE `%s`=?", d.t.Table(), s.String(), field)
+	qs := fmt.Sprintf("UPDATE %s SET %s WHERE %s=?", dl.Quote(d.t.Table()), set, dl.Quote(field))
 	return d.db.Exec(ctx, qs, args...)
 }
 
@@ -87,21 +95,11 @@ func (d *ORMS) AlterOne(ctx context.Context, field string, value any, data map[s
 	if len(data) == 0 {
 		return ae.ErrorInputTooShort
 	}
-
-	var s strings.Builder
-	args := make([]any, 0, len(data)+1)
-	for k, v := range data {
-		args = append(args, v)
-		if s.Len() > 0 {
-			s.WriteString(",")
-		}
-		s.WriteByte('`')
-		s.WriteString(k)
-		s.WriteByte('`')
-		s.WriteString("=?")
-	}
+	dl := d.db.dialect
+	tb := dl.Quote(d.t.Table())
+	set, args := d.assignments(data, len(data)+1)
 	args = append(args, value)
-	qs := fmt.Sprintf("UPDATE `%s` SET %s WHERE `%s`=? LIMIT 1", d.t.Table(), s.String(), field)
+	qs := fmt.Sprintf("UPDATE %s SET %s WHERE %s", tb, set, dl.WhereOne(tb, dl.Quote(field)+"=?"))
 	return d.db.Exec(ctx, qs, args...)
 }
 
@@ -114,6 +112,11 @@ func (d *ORMS) Alter(ctx context.Context, id any, data map[string]any) *ae.Error
 }
 
 func (d *ORMS) Find(ctx context.Context, id any, dst map[string]any) *ae.Error {
+	primary, e := d.t.Indexes().PrimaryKey()
+	if e != nil {
+		return e
+	}
+	dl := d.db.dialect
 	var fields strings.Builder
 	dest := make([]any, 0, len(dst))
 	for k, v := range dst {
@@ -121,10 +124,8 @@ func (d *ORMS) Find(ctx context.Context, id any, dst map[string]any) *ae.Error {
 		if fields.Len() > 0 {
 			fields.WriteByte(',')
 		}
-		fields.WriteByte('`')
-		fields.WriteString(k)
-		fields.WriteByte('`')
+		fields.WriteString(dl.Quote(k))
 	}
-	qs := fmt.Sprintf("SELECT %s FROM `%s` WHERE `%s`=?", fields.String(), d.t.Table(), id)
+	qs := fmt.Sprintf("SELECT %s FROM %s WHERE %s=?", fields.String(), dl.Quote(d.t.Table()), dl.Quote(primary))
 	return d.db.ScanAny(ctx, qs, id, dest...)
 }
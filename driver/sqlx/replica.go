@@ -0,0 +1,126 @@
+package sqlx
+
+import (
+	"context"
+	"database/sql"
+	sqldriver "database/sql/driver"
+	"errors"
+	"net"
+	"sync/atomic"
+
+	"github.com/aarioai/airis-driver/driver"
+	"github.com/aarioai/airis/aa"
+	"github.com/aarioai/airis/aa/ae"
+	"github.com/go-sql-driver/mysql"
+)
+
+// replicaSet routes reads to the replicas of a section. The replica pools are looked up on every read, so they follow
+// hot reloads; a replica is skipped if its pool can't be opened, its circuit breaker is open or its keepalive (see
+// keepalive_interval) is in an outage. A read that can't reach the replica falls back to the primary.
+// The replicas and replica_policy are read again after the primary is reloaded.
+type replicaSet struct {
+	app     *aa.App
+	section string
+	state   atomic.Pointer[replicaState]
+	next    atomic.Uint64
+}
+
+// replicaState is the replicas read while the primary was the pooled client primary
+type replicaState struct {
+	primary  *sql.DB
+	sections []string
+	policy   string
+}
+
+func newReplicaSet(app *aa.App, section string) *replicaSet {
+	return &replicaSet{app: app, section: section}
+}
+
+// load returns the replicas of the primary, they are read again if the primary was swapped by a reload
+func (r *replicaSet) load(primary *sql.DB) *replicaState {
+	s := r.state.Load()
+	if s != nil && (primary == nil || s.primary == primary) {
+		return s
+	}
+	replicas := driver.ParseMysqlReplicas(r.app, r.section)
+	s = &replicaState{primary: primary, sections: replicas.Sections, policy: replicas.Policy}
+	r.state.Store(s)
+	return s
+}
+
+// pick returns a healthy replica, false if none
+func (r *replicaSet) pick(primary *sql.DB) (*sql.DB, string, bool) {
+	s := r.load(primary)
+	n := len(s.sections)
+	if n == 0 {
+		return nil, "", false
+	}
+	start := int(r.next.Add(1)-1) % n
+	var best *sql.DB
+	var bestSection string
+	bestInUse := -1
+	for i := 0; i < n; i++ {
+		section := s.sections[(start+i)%n]
+		if !driver.SectionBreaker(driver.PoolKindMysql, section).Ready() || driver.KeepaliveDown(driver.PoolKindMysql, section) {
+			continue
+		}
+		_, db, e := driver.NewMysqlPool(r.app, section)
+		if e != nil {
+			continue
+		}
+		if s.policy != driver.MysqlReplicaLeastConn {
+			return db, section, true
+		}
+		if inUse := db.Stats().InUse; bestInUse < 0 || inUse < bestInUse {
+			best, bestSection, bestInUse = db, section, inUse
+		}
+	}
+	return best, bestSection, best != nil
+}
+
+type primaryKey struct{}
+
+// UsePrimary routes the reads with ctx to the primary, e.g. to read your own writes
+func UsePrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+func usePrimary(ctx context.Context) bool {
+	v, _ := ctx.Value(primaryKey{}).(bool)
+	return v
+}
+
+// readConn returns the connection and section for a read, the primary if there is no healthy replica
+func (d *DB) readConn(ctx context.Context) (*sql.DB, string, *ae.Error) {
+	primary, e := d.conn()
+	if d.replicas != nil && !usePrimary(ctx) {
+		if db, section, ok := d.replicas.pick(primary); ok {
+			return db, section, nil
+		}
+	}
+	return primary, d.section, e
+}
+
+// readFallback returns the primary if the read on section (a replica) failed as the replica is unreachable, e.g. it's
+// down but neither its breaker nor keepalive has noticed yet
+func (d *DB) readFallback(section string, err error) (*sql.DB, bool) {
+	if section == d.section || !isConnError(err) {
+		return nil, false
+	}
+	db, e := d.conn()
+	return db, e == nil
+}
+
+func isConnError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var opErr *net.OpError
+	return errors.Is(err, sqldriver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) || errors.As(err, &opErr)
+}
+
+// ReadSection returns the section the next read with ctx routes to. Like a read, it advances the round-robin.
+func (d *DB) ReadSection(ctx context.Context) string {
+	_, section, _ := d.readConn(ctx)
+	return section
+}
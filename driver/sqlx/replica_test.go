@@ -0,0 +1,102 @@
+package sqlx_test
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aarioai/airis-driver/driver"
+	"github.com/aarioai/airis-driver/driver/sqlx"
+	"github.com/aarioai/airis/aa"
+	"github.com/aarioai/airis/aa/aconfig"
+	"github.com/aarioai/airis/aa/acontext"
+)
+
+func TestReplicaRouting(t *testing.T) {
+	c, err := aconfig.New("../test_config.ini", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := acontext.WithCancel(acontext.Background())
+	app := aa.New(ctx, cancel, c)
+	defer driver.ClosePools(context.Background(), driver.PoolKindMysql)
+
+	d := sqlx.NewDB(app, "mysql_primary")
+	want := []string{"mysql_r1", "mysql_r2", "mysql_r1", "mysql_r2"}
+	for i, w := range want {
+		if got := d.ReadSection(context.Background()); got != w {
+			t.Errorf("read #%d routed to %s, want %s", i, got, w)
+		}
+	}
+	if got := d.ReadSection(sqlx.UsePrimary(context.Background())); got != "mysql_primary" {
+		t.Errorf("UsePrimary read routed to %s", got)
+	}
+
+	// 熔断的 replica 被跳过
+	b := driver.SectionBreaker(driver.PoolKindMysql, "mysql_r1")
+	if b.Allow() != nil {
+		t.Fatal("mysql_r1 breaker should allow")
+	}
+	b.Done(io.EOF)
+	for i := 0; i < 3; i++ {
+		if got := d.ReadSection(context.Background()); got != "mysql_r2" {
+			t.Errorf("read routed to %s with mysql_r1 breaker open", got)
+		}
+	}
+
+	// replica 连接失败时改读主库
+	recorder := driver.NewTraceRecorder()
+	driver.SetTracer(recorder)
+	defer driver.SetTracer(nil)
+	qctx, qcancel := context.WithTimeout(context.Background(), time.Second)
+	defer qcancel()
+	d.QueryRow(qctx, "SELECT 1")
+	var sections []string
+	for _, span := range recorder.Spans() {
+		sections = append(sections, span.Section)
+	}
+	if len(sections) != 2 || sections[0] != "mysql_r2" || sections[1] != "mysql_primary" {
+		t.Errorf("unreachable replica read should fall back to the primary, spans %v", sections)
+	}
+
+	if got := sqlx.NewDB(app, "mysql_r2").ReadSection(context.Background()); got != "mysql_r2" {
+		t.Errorf("replicas should not be inherited, routed to %s", got)
+	}
+}
+
+func TestReplicaReload(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.ini")
+	ini := "[mysql]\nhost = 127.0.0.1:1\nuser = Aario\npassword = Luexu.com\n\n[mysql_rp]\nreplicas = mysql_rp1\n\n[mysql_rp1]\n[mysql_rp2]\n"
+	if err := os.WriteFile(file, []byte(ini), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := aconfig.New(file, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := acontext.WithCancel(acontext.Background())
+	app := aa.New(ctx, cancel, c)
+	defer driver.ClosePools(context.Background(), driver.PoolKindMysql)
+
+	d := sqlx.NewDB(app, "mysql_rp")
+	if got := d.ReadSection(context.Background()); got != "mysql_rp1" {
+		t.Errorf("read routed to %s, want mysql_rp1", got)
+	}
+	ini = strings.Replace(ini, "replicas = mysql_rp1", "replicas = mysql_rp2", 1)
+	if err = os.WriteFile(file, []byte(ini), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if app.Config, err = aconfig.New(file, nil); err != nil {
+		t.Fatal(err)
+	}
+	if ok, e := driver.ReloadPool(context.Background(), driver.PoolKindMysql, "mysql_rp", time.Millisecond); !ok || e != nil {
+		t.Fatalf("reload mysql_rp: %v %v", ok, e)
+	}
+	if got := d.ReadSection(context.Background()); got != "mysql_rp2" {
+		t.Errorf("read routed to %s after replicas reloaded, want mysql_rp2", got)
+	}
+}
@@ -0,0 +1,43 @@
+package sqlx
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/aarioai/airis-driver/driver"
+	"github.com/aarioai/airis/aa/ae"
+)
+
+// retryPolicy returns the retry policy of the section, writes are retried only with an idempotent ctx
+func (d *DB) retryPolicy(ctx context.Context, write bool) driver.RetryPolicy {
+	if d.section == "" || (write && !driver.IsIdempotent(ctx)) {
+		return driver.RetryPolicy{}
+	}
+	return driver.SectionRetryPolicy(d.kind, d.section)
+}
+
+// Transaction runs fn in a transaction, it commits if fn returns nil, otherwise rolls back.
+// With an idempotent ctx (driver.Idempotent), the whole block is retried on retryable errors (e.g. deadlock), so fn must
+// not have side effects outside the transaction.
+func (d *DB) Transaction(ctx context.Context, opts *sql.TxOptions, fn func(ctx context.Context, tx *Tx) *ae.Error) *ae.Error {
+	if d.error != nil {
+		return d.error
+	}
+	return d.retryPolicy(ctx, true).Do(ctx, func(ctx context.Context) *ae.Error {
+		tx, e := d.Begin(ctx, opts)
+		if e != nil {
+			return e
+		}
+		defer func() {
+			if p := recover(); p != nil {
+				tx.Rollback()
+				panic(p)
+			}
+		}()
+		if e = fn(ctx, tx); e != nil {
+			tx.Rollback()
+			return e
+		}
+		return tx.Commit()
+	})
+}
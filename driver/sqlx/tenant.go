@@ -0,0 +1,34 @@
+package sqlx
+
+import (
+	"context"
+	"sync"
+
+	"github.com/aarioai/airis-driver/driver"
+	"github.com/aarioai/airis/aa"
+)
+
+var (
+	tenantDBs   sync.Map // section -> *DB
+	tenantDBMtx sync.Mutex
+)
+
+// FromContext returns the DB of the tenant in ctx (see driver.TenantSection), section is the default section.
+// DBs are cached per section, so it's fine to call it per request.
+func FromContext(ctx context.Context, app *aa.App, section string) *DB {
+	s, e := driver.TenantSection(ctx, app, driver.PoolKindMysql, section)
+	if e != nil {
+		return NewDriver("", nil, e)
+	}
+	if d, ok := tenantDBs.Load(s); ok {
+		return d.(*DB)
+	}
+	tenantDBMtx.Lock()
+	defer tenantDBMtx.Unlock()
+	if d, ok := tenantDBs.Load(s); ok {
+		return d.(*DB)
+	}
+	d := NewDB(app, s)
+	tenantDBs.Store(s, d)
+	return d
+}
@@ -0,0 +1,65 @@
+package sqlx
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/aarioai/airis-driver/driver"
+	"github.com/aarioai/airis/aa/ae"
+)
+
+// sqlOp traces a statement, and logs it if it's slower than the slow_threshold of the section
+type sqlOp struct {
+	span    driver.Span
+	start   time.Time
+	kind    driver.PoolKind
+	section string
+	query   string
+	args    []any
+	breaker *driver.Breaker
+}
+
+func startOp(ctx context.Context, kind driver.PoolKind, section, name, query string, args []any) (context.Context, *sqlOp) {
+	ctx, span := driver.StartSpan(ctx, driver.TraceOp{Kind: string(kind), Section: section, Name: name, Statement: query})
+	return ctx, &sqlOp{span: span, start: time.Now(), kind: kind, section: section, query: query, args: args}
+}
+
+// startGuardedOp is startOp guarded by the circuit breaker of the section (the primary or a replica), it fails fast
+// with driver.ErrorCircuitOpen
+func (d *DB) startGuardedOp(ctx context.Context, section, name, query string, args []any) (context.Context, *sqlOp, *ae.Error) {
+	b := driver.ContextBreaker(ctx, d.kind, section)
+	if err := b.Allow(); err != nil {
+		return ctx, nil, driver.ErrorCircuitOpen
+	}
+	if section == d.section {
+		section = d.traceSection()
+	}
+	ctx, op := startOp(ctx, d.kind, section, name, query, args)
+	op.breaker = b
+	return ctx, op, nil
+}
+
+func (o *sqlOp) end(err error) {
+	o.breaker.Done(err)
+	driver.EndSpan(o.span, err)
+	driver.LogSlow(o.kind, o.section, o.query, o.args, time.Since(o.start))
+}
+
+// endExec ends the op with rows affected
+func (o *sqlOp) endExec(res sql.Result, err error) {
+	if err == nil && driver.Tracing() {
+		if n, e := res.RowsAffected(); e == nil {
+			o.span.SetAttribute(driver.TraceAttrRowsAffected, n)
+		}
+	}
+	o.end(err)
+}
+
+// traceSection 未通过 NewDB 创建时，以 schema 代替 section
+func (d *DB) traceSection() string {
+	if d.section != "" {
+		return d.section
+	}
+	return d.Schema
+}
@@ -0,0 +1,125 @@
+package driver
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+
+	"github.com/aarioai/airis/aa"
+	"github.com/aarioai/airis/aa/ae"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	TenantPlaceholder = "{id}"
+	maxTenantIdLength = 64
+)
+
+// TenantResolver maps a tenant id to the section of kind, "" means the tenant uses the default section
+type TenantResolver func(app *aa.App, kind PoolKind, tenant string) string
+
+var tenantResolver atomic.Pointer[TenantResolver]
+
+// tenantSectionKeys a tenant section must set the key by itself (or in its url), otherwise all its keys are
+// inherited from the base section and it would silently share the default schema/db
+var tenantSectionKeys = map[PoolKind]string{
+	PoolKindMysql:   "schema",
+	PoolKindMongodb: "db",
+	PoolKindRedis:   "db",
+}
+
+type tenantKey struct{}
+
+// WithTenant binds the tenant id to ctx, see TenantSection
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFromContext returns the tenant id bound by WithTenant, "" if none
+func TenantFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	tenant, _ := ctx.Value(tenantKey{}).(string)
+	return tenant
+}
+
+// SetTenantResolver sets the resolver used by TenantSection, nil disables tenant routing
+func SetTenantResolver(r TenantResolver) {
+	if r == nil {
+		tenantResolver.Store(nil)
+		return
+	}
+	tenantResolver.Store(&r)
+}
+
+// TenantTemplates returns a resolver by section templates, e.g. {PoolKindMysql: "mysql_tenant_{id}"}.
+// The tenant section only needs its own schema (mysql) or db (mongodb/redis), the other keys are inherited from the
+// base section, e.g. [mysql]. Tenants without their own section, or kinds without a template, use the default section.
+//
+//	[mysql_tenant_42]
+//	schema = tenant_42
+func TenantTemplates(templates map[PoolKind]string) TenantResolver {
+	return func(app *aa.App, kind PoolKind, tenant string) string {
+		tpl, ok := templates[kind]
+		if !ok {
+			return ""
+		}
+		section := strings.ReplaceAll(tpl, TenantPlaceholder, tenant)
+		if !isTenantSection(app, kind, section) {
+			return ""
+		}
+		return section
+	}
+}
+
+func isTenantSection(app *aa.App, kind PoolKind, section string) bool {
+	key, ok := tenantSectionKeys[kind]
+	if !ok {
+		return false
+	}
+	if v, err := app.Config.MustGetString(section + "." + key); err == nil && v != "" {
+		return true
+	}
+	_, ok, _ = sectionURLCfg(app, string(kind), section, key)
+	return ok
+}
+
+// validTenantId tenant ids are put into section names, only letters, digits, '_' and '-' are allowed
+func validTenantId(tenant string) bool {
+	if len(tenant) > maxTenantIdLength {
+		return false
+	}
+	for _, c := range tenant {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// TenantSection returns the section of kind for the tenant in ctx, defaultSection if ctx has no tenant, no resolver
+// is set or the resolver returns ""
+func TenantSection(ctx context.Context, app *aa.App, kind PoolKind, defaultSection string) (string, *ae.Error) {
+	tenant := TenantFromContext(ctx)
+	r := tenantResolver.Load()
+	if tenant == "" || r == nil {
+		return defaultSection, nil
+	}
+	if !validTenantId(tenant) {
+		return "", ae.New(ae.BadRequest, "invalid tenant id")
+	}
+	if section := (*r)(app, kind, tenant); section != "" {
+		return section, nil
+	}
+	return defaultSection, nil
+}
+
+// RedisFromContext returns the pooled redis client of the tenant in ctx, see TenantSection
+func RedisFromContext(ctx context.Context, app *aa.App, section string) (*redis.Client, *ae.Error) {
+	s, e := TenantSection(ctx, app, PoolKindRedis, section)
+	if e != nil {
+		return nil, e
+	}
+	return NewRedisPool(app, s)
+}
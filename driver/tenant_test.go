@@ -0,0 +1,57 @@
+package driver_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aarioai/airis-driver/driver"
+	"github.com/aarioai/airis/aa"
+	"github.com/aarioai/airis/aa/aconfig"
+	"github.com/aarioai/airis/aa/acontext"
+	"github.com/aarioai/airis/aa/ae"
+)
+
+func TestTenantSection(t *testing.T) {
+	c, err := aconfig.New("./test_config.ini", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := acontext.WithCancel(acontext.Background())
+	app := aa.New(ctx, cancel, c)
+
+	driver.SetTenantResolver(driver.TenantTemplates(map[driver.PoolKind]string{
+		driver.PoolKindMysql: "mysql_tenant_{id}",
+		driver.PoolKindRedis: "redis_tenant_{id}",
+	}))
+	defer driver.SetTenantResolver(nil)
+
+	testCases := []struct {
+		tenant string
+		kind   driver.PoolKind
+		want   string
+	}{
+		{"", driver.PoolKindMysql, "mysql"},
+		{"42", driver.PoolKindMysql, "mysql_tenant_42"},
+		{"42", driver.PoolKindRedis, "redis_tenant_42"},
+		{"42", driver.PoolKindMongodb, "mongodb"}, // no template
+		{"7", driver.PoolKindMysql, "mysql"},      // no tenant section
+	}
+	for _, tc := range testCases {
+		got, e := driver.TenantSection(driver.WithTenant(context.Background(), tc.tenant), app, tc.kind, string(tc.kind))
+		if e != nil || got != tc.want {
+			t.Errorf("TenantSection(%q, %s) = %q, %v, want %q", tc.tenant, tc.kind, got, e, tc.want)
+		}
+	}
+	if _, e := driver.TenantSection(driver.WithTenant(context.Background(), "42]\nschema"), app, driver.PoolKindMysql, "mysql"); e == nil || e.Code != ae.BadRequest {
+		t.Errorf("TenantSection with invalid tenant id: %v", e)
+	}
+
+	client, e := driver.RedisFromContext(driver.WithTenant(context.Background(), "42"), app, "redis")
+	if e != nil {
+		t.Fatal(e.Msg)
+	}
+	defer driver.ClosePool(context.Background(), driver.PoolKindRedis, "redis_tenant_42")
+	if db := client.Options().DB; db != 3 {
+		t.Errorf("tenant redis db = %d, want 3", db)
+	}
+}
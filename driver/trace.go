@@ -0,0 +1,403 @@
+package driver
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/wagslane/go-rabbitmq"
+	"go.mongodb.org/mongo-driver/v2/event"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// 追踪的操作类型，与 PoolKind 一致，另有 http
+const (
+	TraceKindMysql    = string(PoolKindMysql)
+	TraceKindPostgres = string(PoolKindPostgres)
+	TraceKindSqlite   = string(PoolKindSqlite)
+	TraceKindRedis    = string(PoolKindRedis)
+	TraceKindMongodb  = string(PoolKindMongodb)
+	TraceKindRabbitmq = string(PoolKindRabbitmq)
+	TraceKindHttp     = "http"
+)
+
+// span attributes set by the driver
+const (
+	TraceAttrRowsAffected = "db.rows_affected"
+	TraceAttrErrorClass   = "error.class"
+	TraceAttrHttpStatus   = "http.status_code"
+)
+
+// TraceOp describes a driver operation
+type TraceOp struct {
+	Kind      string // TraceKindMysql, TraceKindRedis ...
+	Section   string
+	Name      string // e.g. query, exec, get, find, publish, GET
+	Statement string // redacted SQL, redis command and key, mongodb command and collection, routing keys or url
+}
+
+type Span interface {
+	SetAttribute(key string, value any)
+	// End finishes the span, err is nil on success
+	End(err error)
+}
+
+// Tracer is implemented by the app, see package otelx for an OpenTelemetry adapter
+type Tracer interface {
+	Start(ctx context.Context, op TraceOp) (context.Context, Span)
+}
+
+type tracerHolder struct {
+	tracer Tracer
+}
+
+var globalTracer atomic.Pointer[tracerHolder]
+
+// SetTracer sets the tracer of all driver operations, nil disables tracing
+func SetTracer(t Tracer) {
+	if t == nil {
+		globalTracer.Store(nil)
+		return
+	}
+	globalTracer.Store(&tracerHolder{tracer: t})
+}
+
+// Tracing reports whether a tracer is set
+func Tracing() bool {
+	return globalTracer.Load() != nil
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttribute(key string, value any) {}
+func (noopSpan) End(err error)                      {}
+
+// StartSpan starts a span with the tracer set by SetTracer, the statement is redacted
+func StartSpan(ctx context.Context, op TraceOp) (context.Context, Span) {
+	h := globalTracer.Load()
+	if h == nil {
+		return ctx, noopSpan{}
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	op.Statement = Redact(op.Statement)
+	return h.tracer.Start(ctx, op)
+}
+
+// EndSpan sets the error class and ends the span
+func EndSpan(span Span, err error) {
+	if err != nil {
+		span.SetAttribute(TraceAttrErrorClass, ErrorClass(err))
+	}
+	span.End(err)
+}
+
+// ErrorClass classifies an error for tracing: canceled, timeout, not_found or error
+func ErrorClass(err error) string {
+	if err == nil {
+		return ""
+	}
+	if errors.Is(err, context.Canceled) {
+		return "canceled"
+	}
+	var ne net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &ne) && ne.Timeout()) {
+		return "timeout"
+	}
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, redis.Nil) || errors.Is(err, mongo.ErrNoDocuments) {
+		return "not_found"
+	}
+	return "error"
+}
+
+// redisHook traces redis commands, logs slow ones, guards them with the circuit breaker and retries them with the
+// retry policy of the section.
+// The statement is the command name and key, values are never traced
+type redisHook struct {
+	section string
+}
+
+func redisStatement(cmd redis.Cmder) string {
+	args := cmd.Args()
+	if len(args) > 1 {
+		if key, ok := args[1].(string); ok {
+			return cmd.Name() + " " + key
+		}
+	}
+	return cmd.Name()
+}
+
+func (h redisHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (h redisHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	process := func(ctx context.Context, cmd redis.Cmder) error {
+		b := ContextBreaker(ctx, PoolKindRedis, h.section)
+		if err := b.Allow(); err != nil {
+			cmd.SetErr(err)
+			return err
+		}
+		_, slow := slowThreshold(PoolKindRedis, h.section)
+		if !slow && !Tracing() {
+			err := next(ctx, cmd)
+			b.Done(err)
+			return err
+		}
+		statement := redisStatement(cmd)
+		ctx, span := StartSpan(ctx, TraceOp{Kind: TraceKindRedis, Section: h.section, Name: cmd.Name(), Statement: statement})
+		start := time.Now()
+		err := next(ctx, cmd)
+		b.Done(err)
+		EndSpan(span, err)
+		if slow {
+			var args []any
+			if a := cmd.Args(); len(a) > 2 {
+				args = a[2:]
+			}
+			LogSlow(PoolKindRedis, h.section, statement, args, time.Since(start))
+		}
+		return err
+	}
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		p := SectionRetryPolicy(PoolKindRedis, h.section)
+		if !p.Enabled() || !redisRetryable(ctx, cmd) {
+			return process(ctx, cmd)
+		}
+		return p.doErr(ctx, func() error { return process(ctx, cmd) })
+	}
+}
+
+func (h redisHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	process := func(ctx context.Context, cmds []redis.Cmder) error {
+		b := ContextBreaker(ctx, PoolKindRedis, h.section)
+		if err := b.Allow(); err != nil {
+			for _, cmd := range cmds {
+				cmd.SetErr(err)
+			}
+			return err
+		}
+		_, slow := slowThreshold(PoolKindRedis, h.section)
+		if !slow && !Tracing() {
+			err := next(ctx, cmds)
+			b.Done(err)
+			return err
+		}
+		statements := make([]string, len(cmds))
+		for i, cmd := range cmds {
+			statements[i] = redisStatement(cmd)
+		}
+		statement := strings.Join(statements, "; ")
+		ctx, span := StartSpan(ctx, TraceOp{Kind: TraceKindRedis, Section: h.section, Name: "pipeline", Statement: statement})
+		start := time.Now()
+		err := next(ctx, cmds)
+		b.Done(err)
+		EndSpan(span, err)
+		if slow {
+			LogSlow(PoolKindRedis, h.section, statement, nil, time.Since(start))
+		}
+		return err
+	}
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		p := SectionRetryPolicy(PoolKindRedis, h.section)
+		if !p.Enabled() || !redisRetryable(ctx, cmds...) {
+			return process(ctx, cmds)
+		}
+		return p.doErr(ctx, func() error { return process(ctx, cmds) })
+	}
+}
+
+type mongodbCommand struct {
+	span      Span
+	statement string
+	args      []any
+}
+
+// mongodbCommandArgs are the command fields except the command name, session and $-prefixed ones
+func mongodbCommandArgs(e *event.CommandStartedEvent) []any {
+	elems, err := e.Command.Elements()
+	if err != nil {
+		return nil
+	}
+	var args []any
+	for _, elem := range elems {
+		key := elem.Key()
+		if key == e.CommandName || key == "lsid" || key == "txnNumber" || strings.HasPrefix(key, "$") {
+			continue
+		}
+		args = append(args, key+"="+truncateArg(elem.Value().String()))
+	}
+	return args
+}
+
+// mongodbCommandMonitor traces mongodb commands and logs slow ones, the statement is the command name and collection
+func mongodbCommandMonitor(section string) *event.CommandMonitor {
+	var commands sync.Map // request id -> *mongodbCommand
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			_, slow := slowThreshold(PoolKindMongodb, section)
+			if !slow && !Tracing() {
+				return
+			}
+			statement := e.CommandName + " " + e.DatabaseName
+			if coll, ok := e.Command.Lookup(e.CommandName).StringValueOK(); ok {
+				statement += "." + coll
+			}
+			c := &mongodbCommand{statement: statement}
+			if slow {
+				c.args = mongodbCommandArgs(e)
+			}
+			_, c.span = StartSpan(ctx, TraceOp{Kind: TraceKindMongodb, Section: section, Name: e.CommandName, Statement: statement})
+			commands.Store(e.RequestID, c)
+		},
+		Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+			if v, ok := commands.LoadAndDelete(e.RequestID); ok {
+				c := v.(*mongodbCommand)
+				if n, ok := e.Reply.Lookup("n").AsInt64OK(); ok {
+					c.span.SetAttribute(TraceAttrRowsAffected, n)
+				}
+				EndSpan(c.span, nil)
+				LogSlow(PoolKindMongodb, section, c.statement, c.args, e.Duration)
+			}
+		},
+		Failed: func(ctx context.Context, e *event.CommandFailedEvent) {
+			if v, ok := commands.LoadAndDelete(e.RequestID); ok {
+				c := v.(*mongodbCommand)
+				EndSpan(c.span, e.Failure)
+				LogSlow(PoolKindMongodb, section, c.statement, c.args, e.Duration)
+			}
+		},
+	}
+}
+
+// httpTraceTransport traces http client round trips, the statement is the url without query
+type httpTraceTransport struct {
+	section string
+	base    http.RoundTripper
+}
+
+func (t httpTraceTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if !Tracing() {
+		return t.base.RoundTrip(r)
+	}
+	u := *r.URL
+	u.RawQuery = ""
+	u.User = nil
+	ctx, span := StartSpan(r.Context(), TraceOp{Kind: TraceKindHttp, Section: t.section, Name: r.Method, Statement: u.String()})
+	resp, err := t.base.RoundTrip(r.WithContext(ctx))
+	if err == nil {
+		span.SetAttribute(TraceAttrHttpStatus, resp.StatusCode)
+	}
+	EndSpan(span, err)
+	return resp, err
+}
+
+// TracedPublisher traces rabbitmq publishing, the statement is the routing keys
+type TracedPublisher struct {
+	*rabbitmq.Publisher
+	section string
+}
+
+func NewTracedPublisher(section string, publisher *rabbitmq.Publisher) *TracedPublisher {
+	return &TracedPublisher{Publisher: publisher, section: section}
+}
+
+func (p *TracedPublisher) Publish(data []byte, routingKeys []string, optionFuncs ...func(*rabbitmq.PublishOptions)) error {
+	return p.PublishWithContext(context.Background(), data, routingKeys, optionFuncs...)
+}
+
+func (p *TracedPublisher) PublishWithContext(ctx context.Context, data []byte, routingKeys []string, optionFuncs ...func(*rabbitmq.PublishOptions)) error {
+	ctx, span := StartSpan(ctx, TraceOp{Kind: TraceKindRabbitmq, Section: p.section, Name: "publish", Statement: strings.Join(routingKeys, ",")})
+	err := p.Publisher.PublishWithContext(ctx, data, routingKeys, optionFuncs...)
+	EndSpan(span, err)
+	return err
+}
+
+// TraceConsumeHandler traces rabbitmq consuming, a nack is reported as an error
+func TraceConsumeHandler(section, queue string, handler rabbitmq.Handler) rabbitmq.Handler {
+	return func(d rabbitmq.Delivery) rabbitmq.Action {
+		_, span := StartSpan(context.Background(), TraceOp{Kind: TraceKindRabbitmq, Section: section, Name: "consume", Statement: queue + " " + d.RoutingKey})
+		action := handler(d)
+		var err error
+		switch action {
+		case rabbitmq.NackDiscard:
+			err = errors.New("nack discard")
+		case rabbitmq.NackRequeue:
+			err = errors.New("nack requeue")
+		}
+		EndSpan(span, err)
+		return action
+	}
+}
+
+// RecordedSpan is a span recorded by TraceRecorder
+type RecordedSpan struct {
+	TraceOp
+	Attributes map[string]any
+	Err        error
+	Start      time.Time
+	Duration   time.Duration
+}
+
+// TraceRecorder is an in-memory Tracer, e.g. for tests
+type TraceRecorder struct {
+	mtx   sync.Mutex
+	spans []RecordedSpan
+}
+
+func NewTraceRecorder() *TraceRecorder {
+	return &TraceRecorder{}
+}
+
+type recorderSpan struct {
+	r    *TraceRecorder
+	mtx  sync.Mutex
+	span RecordedSpan
+}
+
+func (r *TraceRecorder) Start(ctx context.Context, op TraceOp) (context.Context, Span) {
+	return ctx, &recorderSpan{r: r, span: RecordedSpan{TraceOp: op, Start: time.Now()}}
+}
+
+func (s *recorderSpan) SetAttribute(key string, value any) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if s.span.Attributes == nil {
+		s.span.Attributes = make(map[string]any)
+	}
+	s.span.Attributes[key] = value
+}
+
+func (s *recorderSpan) End(err error) {
+	s.mtx.Lock()
+	span := s.span
+	s.mtx.Unlock()
+	span.Err = err
+	span.Duration = time.Since(span.Start)
+	s.r.mtx.Lock()
+	s.r.spans = append(s.r.spans, span)
+	s.r.mtx.Unlock()
+}
+
+// Spans returns the ended spans in order
+func (r *TraceRecorder) Spans() []RecordedSpan {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	spans := make([]RecordedSpan, len(r.spans))
+	copy(spans, r.spans)
+	return spans
+}
+
+func (r *TraceRecorder) Reset() {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.spans = nil
+}
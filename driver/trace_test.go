@@ -0,0 +1,82 @@
+package driver_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aarioai/airis-driver/driver"
+	"github.com/aarioai/airis/aa"
+	"github.com/aarioai/airis/aa/aconfig"
+	"github.com/aarioai/airis/aa/acontext"
+	"github.com/wagslane/go-rabbitmq"
+)
+
+func TestTraceRecorder(t *testing.T) {
+	c, err := aconfig.New("./test_config.ini", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := acontext.WithCancel(acontext.Background())
+	app := aa.New(ctx, cancel, c)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer srv.Close()
+
+	recorder := driver.NewTraceRecorder()
+	driver.SetTracer(recorder)
+	defer driver.SetTracer(nil)
+
+	client, e := driver.NewHttpClient(app, "httpc_trace")
+	if e != nil {
+		t.Fatal(e.Msg)
+	}
+	resp, err := client.Get(srv.URL + "/ping?token=Secret1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	handler := driver.TraceConsumeHandler("rabbitmq", "orders", func(d rabbitmq.Delivery) rabbitmq.Action {
+		return rabbitmq.NackDiscard
+	})
+	handler(rabbitmq.Delivery{})
+
+	spans := recorder.Spans()
+	if len(spans) != 2 {
+		t.Fatalf("recorded spans: %+v", spans)
+	}
+	h := spans[0]
+	if h.Kind != driver.TraceKindHttp || h.Section != "httpc_trace" || h.Name != http.MethodGet || h.Statement != srv.URL+"/ping" || h.Attributes[driver.TraceAttrHttpStatus] != http.StatusTeapot {
+		t.Errorf("http span: %+v", h)
+	}
+	r := spans[1]
+	if r.Kind != driver.TraceKindRabbitmq || r.Name != "consume" || r.Err == nil || r.Attributes[driver.TraceAttrErrorClass] != "error" {
+		t.Errorf("consume span: %+v", r)
+	}
+
+	recorder.Reset()
+	driver.SetTracer(nil)
+	if _, span := driver.StartSpan(context.Background(), driver.TraceOp{}); driver.Tracing() || span == nil {
+		t.Errorf("tracing should be disabled")
+	}
+	if n := len(recorder.Spans()); n != 0 {
+		t.Errorf("recorder should be reset: %d", n)
+	}
+
+	for err, class := range map[error]string{
+		context.Canceled: "canceled",
+		fmt.Errorf("read: %w", context.DeadlineExceeded): "timeout",
+		sql.ErrNoRows:      "not_found",
+		fmt.Errorf("boom"): "error",
+	} {
+		if got := driver.ErrorClass(err); got != class {
+			t.Errorf("ErrorClass(%v) = %s, want %s", err, got, class)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package driver
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aarioai/airis/aa"
+	"github.com/aarioai/airis/aa/ae"
+	"github.com/aarioai/airis/aa/alog"
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/redis/go-redis/v9"
+	"github.com/wagslane/go-rabbitmq"
+)
+
+const (
+	DefaultWarmupTimeout          = 30 * time.Second
+	DefaultWarmupRetryInterval    = 200 * time.Millisecond
+	DefaultWarmupMaxRetryInterval = 5 * time.Second
+
+	// database/sql 默认 maxIdle
+	defaultMysqlMaxIdleConns = 2
+)
+
+// WarmupOptions lists the sections to open at startup
+type WarmupOptions struct {
+	Sections map[PoolKind][]string
+
+	// NewRabbitmqPool / NewInfluxdbPool 的参数
+	RabbitmqTLSConfig   *tls.Config
+	RabbitmqSASL        []amqp091.Authentication
+	RabbitmqOptions     []func(*rabbitmq.ConnectionOptions)
+	InfluxdbDefaultTags map[string]string
+
+	Timeout          time.Duration // overall deadline, default DefaultWarmupTimeout
+	RetryInterval    time.Duration // first retry backoff, doubled after each failure
+	MaxRetryInterval time.Duration
+}
+
+// WarmupResult is the warm-up state of a section
+type WarmupResult struct {
+	Kind     PoolKind      `json:"kind"`
+	Section  string        `json:"section"`
+	Attempts int           `json:"attempts"`
+	Elapsed  time.Duration `json:"elapsed"`
+	Conns    int           `json:"conns"` // idle connections opened in advance
+	Error    string        `json:"error,omitempty"`
+}
+
+func (r WarmupResult) OK() bool {
+	return r.Error == ""
+}
+
+type WarmupReport struct {
+	Results []WarmupResult `json:"results"`
+	Elapsed time.Duration  `json:"elapsed"`
+}
+
+func (r WarmupReport) OK() bool {
+	for _, result := range r.Results {
+		if !result.OK() {
+			return false
+		}
+	}
+	return true
+}
+
+// Err joins the errors of all failed sections, or returns nil
+func (r WarmupReport) Err() error {
+	var errs []error
+	for _, result := range r.Results {
+		if !result.OK() {
+			errs = append(errs, fmt.Errorf("%s [%s]: %s", result.Kind, result.Section, result.Error))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (r WarmupReport) String() string {
+	var s strings.Builder
+	fmt.Fprintf(&s, "driver warmup finished in %s", r.Elapsed)
+	for _, result := range r.Results {
+		status := "ok"
+		if !result.OK() {
+			status = "failed: " + result.Error
+		}
+		fmt.Fprintf(&s, "\n  %s [%s] %s, attempts %d, conns %d, %s", result.Kind, result.Section, result.Elapsed, result.Attempts, result.Conns, status)
+	}
+	return s.String()
+}
+
+// Warmup opens the pooled clients of all sections concurrently, fills the MySQL/Redis/Mongo pools to their min idle
+// sizes, and waits with backoff until every dependency answers or the deadline is exceeded.
+// Config errors are not retried.
+func Warmup(ctx context.Context, app *aa.App, opts WarmupOptions) WarmupReport {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = DefaultWarmupTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	var report WarmupReport
+	var mtx sync.Mutex
+	var wg sync.WaitGroup
+	for _, kind := range PoolKinds {
+		for _, section := range opts.Sections[kind] {
+			wg.Add(1)
+			go func(kind PoolKind, section string) {
+				defer wg.Done()
+				result := warmupSection(ctx, app, kind, section, opts)
+				mtx.Lock()
+				report.Results = append(report.Results, result)
+				mtx.Unlock()
+			}(kind, section)
+		}
+	}
+	wg.Wait()
+	report.Elapsed = time.Since(start)
+	slices.SortFunc(report.Results, func(a, b WarmupResult) int {
+		if c := strings.Compare(string(a.Kind), string(b.Kind)); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Section, b.Section)
+	})
+	return report
+}
+
+func warmupSection(ctx context.Context, app *aa.App, kind PoolKind, section string, opts WarmupOptions) WarmupResult {
+	result := WarmupResult{Kind: kind, Section: section}
+	start := time.Now()
+	defer func() {
+		result.Elapsed = time.Since(start)
+	}()
+
+	backoff := opts.RetryInterval
+	if backoff <= 0 {
+		backoff = DefaultWarmupRetryInterval
+	}
+	maxBackoff := opts.MaxRetryInterval
+	if maxBackoff <= 0 {
+		maxBackoff = DefaultWarmupMaxRetryInterval
+	}
+	for {
+		result.Attempts++
+		conns, retryable, err := warmupPool(ctx, app, kind, section, opts)
+		if err == nil {
+			result.Conns = conns
+			result.Error = ""
+			return result
+		}
+		result.Error = Redact(err.Error())
+		if !retryable {
+			return result
+		}
+		alog.Printf("warmup %s [%s] attempt %d: %s", kind, section, result.Attempts, result.Error)
+		select {
+		case <-ctx.Done():
+			result.Error += " (" + ctx.Err().Error() + ")"
+			return result
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// warmupPool opens the pooled client, pings it and opens idle connections in advance.
+// Config errors are not retryable.
+func warmupPool(ctx context.Context, app *aa.App, kind PoolKind, section string, opts WarmupOptions) (conns int, retryable bool, err error) {
+	var e *ae.Error
+	switch kind {
+	case PoolKindMysql:
+		_, _, e = NewMysqlPool(app, section)
+	case PoolKindPostgres:
+		_, _, e = NewPostgresPool(app, section)
+	case PoolKindSqlite:
+		_, _, e = NewSqlitePool(app, section)
+	case PoolKindRedis:
+		_, e = NewRedisPool(app, section)
+	case PoolKindMongodb:
+		_, _, e = NewMongodbPool(app, section)
+	case PoolKindRabbitmq:
+		_, e = NewRabbitmqPool(app, section, opts.RabbitmqTLSConfig, opts.RabbitmqSASL, opts.RabbitmqOptions)
+	case PoolKindInfluxdb:
+		_, e = NewInfluxdbPool(app, section, opts.InfluxdbDefaultTags)
+	default:
+		return 0, false, fmt.Errorf("unknown pool kind %s", kind)
+	}
+	if e != nil {
+		return 0, e.Code != ae.VariantAlsoNegotiates, errors.New(e.Msg)
+	}
+	entry, ok := pools.load(poolKey{kind: kind, section: section})
+	if !ok {
+		return 0, true, errors.New("closed while warming up")
+	}
+	if err = pingPoolClient(ctx, entry.client); err != nil {
+		return 0, true, err
+	}
+
+	switch c := entry.client.(type) {
+	case SqlClientData:
+		pool := sqlPoolOptionsOf(entry.config)
+		n := pool.MaxIdleConns
+		if n <= 0 {
+			n = defaultMysqlMaxIdleConns
+		}
+		if maxOpen := pool.MaxOpenConns; maxOpen > 0 {
+			n = min(n, maxOpen)
+		}
+		n, err = warmupConns(n, func() error {
+			return c.Client.PingContext(ctx)
+		})
+		return n, true, err
+	case *redis.Client:
+		conns, err = warmupConns(c.Options().MinIdleConns, func() error {
+			return c.Ping(ctx).Err()
+		})
+		return conns, true, err
+	case MongodbClientData:
+		var n int
+		if o := entry.config.(*MongodbOptions).ConnectionOptions; o != nil {
+			n = int(o.MinPoolSize)
+		}
+		n, err = warmupConns(n, func() error {
+			return c.Client.Ping(ctx, nil)
+		})
+		return n, true, err
+	}
+	return 0, true, nil
+}
+
+// warmupConns runs n pings concurrently, so that n connections are opened and then put back to the pool as idle
+func warmupConns(n int, ping func() error) (int, error) {
+	if n <= 0 {
+		return 0, nil
+	}
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = ping()
+		}(i)
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
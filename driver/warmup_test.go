@@ -0,0 +1,41 @@
+package driver_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aarioai/airis-driver/driver"
+	"github.com/aarioai/airis/aa"
+	"github.com/aarioai/airis/aa/aconfig"
+	"github.com/aarioai/airis/aa/acontext"
+)
+
+func TestWarmup(t *testing.T) {
+	c, err := aconfig.New("./test_config.ini", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := acontext.WithCancel(acontext.Background())
+	app := aa.New(ctx, cancel, c)
+	defer driver.ClosePools(context.Background(), driver.PoolKindRedis)
+
+	report := driver.Warmup(context.Background(), app, driver.WarmupOptions{
+		Sections: map[driver.PoolKind][]string{
+			driver.PoolKindRedis:    {"redis_test"}, // unreachable
+			driver.PoolKindInfluxdb: {"influxdb"},   // not configured
+		},
+		Timeout:       300 * time.Millisecond,
+		RetryInterval: 50 * time.Millisecond,
+	})
+	if report.OK() || report.Err() == nil || len(report.Results) != 2 {
+		t.Fatalf("warmup unreachable dependencies: %s", report)
+	}
+	influx, redis := report.Results[0], report.Results[1]
+	if influx.Kind != driver.PoolKindInfluxdb || influx.Attempts != 1 {
+		t.Errorf("config error should not be retried: %+v", influx)
+	}
+	if redis.Kind != driver.PoolKindRedis || redis.Attempts < 2 || redis.Elapsed > time.Second {
+		t.Errorf("unreachable redis should be retried until deadline: %+v", redis)
+	}
+}